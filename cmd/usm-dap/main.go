@@ -0,0 +1,49 @@
+//Command usm-dap is a Debug Adapter Protocol server for usm bytecode programs.
+//It serves a single session over stdin and stdout, or sessions over TCP with -listen.
+//Debugged programs may read the files of the machine, so -listen only accepts loopback addresses.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/qlova/usm/target/runtime/dap"
+)
+
+func main() {
+	var listen = flag.String("listen", "", "loopback address to listen on, such as 127.0.0.1:4711, instead of serving over stdio")
+	flag.Parse()
+
+	var err error
+	if *listen != "" {
+		if err = loopback(*listen); err == nil {
+			err = dap.ListenAndServe(*listen)
+		}
+	} else {
+		err = dap.Serve(stdio{})
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+//loopback returns an error if the address is not on a loopback interface.
+func loopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("usm-dap: %v is not a loopback address", address)
+	}
+	return nil
+}
+
+//stdio reads from stdin and writes to stdout.
+type stdio struct{}
+
+func (stdio) Read(b []byte) (int, error)  { return os.Stdin.Read(b) }
+func (stdio) Write(b []byte) (int, error) { return os.Stdout.Write(b) }
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"os"
 
 	"github.com/qlova/usm/target/bytecode"
 	"github.com/qlova/usm/target/golang"
@@ -20,7 +21,10 @@ func main() {
 	var buffer bytes.Buffer
 	c.WriteTo(&buffer)
 
-	fmt.Println("Bytecode: ", buffer.Bytes())
+	fmt.Println("Bytecode: ")
+	if err := bytecode.Disassemble(os.Stdout, bytes.NewReader(buffer.Bytes())); err != nil {
+		fmt.Println(err)
+	}
 
 	var r runtime.Target
 	bytecode.NewReader(bytes.NewReader(buffer.Bytes())).Target(&r)
@@ -40,6 +40,8 @@ type (
 )
 
 //Register is a reference to a value.
+//Each function numbers its registers from 1, independently of any enclosing function,
+//and its arguments are the negative registers returned by Arg.
 type Register int
 
 //Block is a block of code.
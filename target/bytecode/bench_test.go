@@ -0,0 +1,113 @@
+package bytecode_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/bytecode"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//programs are the programs that the benchmarks encode, from small to large.
+var programs = []struct {
+	name    string
+	program func(c usm.Target)
+}{
+	{"hello", func(c usm.Target) {
+		c.Main(func() {
+			var hello = c.Define(0, func() {
+				c.Discard(c.Send(nil, c.String("Hello World\n")))
+			})
+			c.JumpTo(hello)
+		})
+	}},
+	{"factorial", func(c usm.Target) {
+		var one = c.Number(big.NewInt(1))
+		c.Main(func() {
+			const factorial = 1
+			c.Define(1, func() {
+				c.If(c.Less(c.Get(usm.Arg(0)), c.Number(big.NewInt(2))), func() {
+					c.Return(one)
+				}, nil, nil)
+				c.Return(c.Mul(c.Get(usm.Arg(0)), c.Call(factorial, c.Sub(c.Get(usm.Arg(0)), one))))
+			})
+			c.Discard(c.Call(factorial, c.Number(big.NewInt(20))))
+		})
+	}},
+	//numbers holds small, large and negative numbers, most of which are only used once.
+	{"numbers", func(c usm.Target) {
+		c.Main(func() {
+			var n = big.NewInt(1)
+			for i := 0; i < 1000; i++ {
+				n.Mul(n, big.NewInt(-3))
+				c.Var(c.Number(new(big.Int).Set(n)))
+				c.Var(c.Number(big.NewInt(int64(i))))
+			}
+		})
+	}},
+	//functions is a chain of functions with several arguments that each call the one before them.
+	{"functions", func(c usm.Target) {
+		c.Main(func() {
+			var previous = c.Define(3, func() {
+				c.Return(c.Add(c.Get(usm.Arg(0)), c.Get(usm.Arg(2))))
+			})
+			for i := 0; i < 500; i++ {
+				var callee = previous
+				previous = c.Define(3, func() {
+					var sum = c.Var(c.Add(c.Get(usm.Arg(0)), c.Get(usm.Arg(1))))
+					c.Return(c.Call(callee, c.Get(sum), c.Get(usm.Arg(1)), c.String("argument")))
+				})
+			}
+			c.Discard(c.Call(previous, c.Number(big.NewInt(1)), c.Number(big.NewInt(2)), c.Number(big.NewInt(3))))
+		})
+	}},
+}
+
+//encode returns the bytecode of the program.
+func encode(b *testing.B, program func(c usm.Target)) []byte {
+	var c bytecode.Target
+	program(&c)
+	var buffer bytes.Buffer
+	if _, err := c.WriteTo(&buffer); err != nil {
+		b.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+//BenchmarkWrite builds and encodes each program and reports the size of its bytecode,
+//along with the size that it has when integers are written as 8 bytes instead of varints.
+func BenchmarkWrite(b *testing.B) {
+	for _, p := range programs {
+		b.Run(p.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				size = len(encode(b, p.program))
+			}
+			b.StopTimer()
+			bytecode.FixedWidth(true)
+			defer bytecode.FixedWidth(false)
+			b.ReportMetric(float64(size), "bytes/program")
+			b.ReportMetric(float64(len(encode(b, p.program))), "fixed-bytes/program")
+		})
+	}
+}
+
+//BenchmarkRead decodes each program into a runtime.Target.
+func BenchmarkRead(b *testing.B) {
+	for _, p := range programs {
+		b.Run(p.name, func(b *testing.B) {
+			var code = encode(b, p.program)
+			b.SetBytes(int64(len(code)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var r runtime.Target
+				if err := bytecode.NewReader(bytes.NewReader(code)).Target(&r); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(code)), "bytes/program")
+		})
+	}
+}
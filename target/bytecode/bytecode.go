@@ -6,124 +6,704 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
 
 	"github.com/qlova/usm"
 )
 
+//Error is an error in a bytecode stream, at the instruction with the opcode that begins at the offset.
+type Error struct {
+	Offset int64
+	Opcode byte
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("bytecode: %v at offset %v: %v", Name(e.Opcode), e.Offset, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//binding is what a bytecode register refers to in the target.
+//Registers created by Var refer to a register of the target, the registers of Each and Range refer to a value.
+type binding struct {
+	register usm.Register
+	value    usm.Value
+}
+
+//labeller is implemented by targets that number their functions in the order that Define is called,
+//such as the targets built on template.Target. The Reader is only able to decode a function that
+//refers to itself, or to an enclosing function, when the target is a labeller.
+type labeller interface {
+	NextLabel() usm.Label
+}
+
 //Reader is bytecode reader.
+//Labels and registers are numbered by the order they are created in the bytecode,
+//the Reader maps them to the labels and registers returned by the target.
 type Reader struct {
 	*bufio.Reader
-	labels int64
+
+	//Header is the header of the stream.
+	Header Header
+
+	//offset is the number of bytes read.
+	offset int64
+
+	//err is the error from reading the header and the constants.
+	err error
+
+	//constants are the strings and numbers of the constant section.
+	constants []interface{}
+
+	labels    []usm.Label
+	registers map[usm.Register]binding
+
+	//depth is the number of values and statements that are being read.
+	depth int
 }
 
-//NewReader creates a bytecode.Reader from the reader.
+//NewReader creates a bytecode.Reader from the reader, validates the header of the stream and reads its constants.
+//Any error with the header or the constants is returned by Err and Target.
 func NewReader(r io.Reader) Reader {
-	return Reader{Reader: bufio.NewReader(r)}
+	var reader = Reader{Reader: bufio.NewReader(r)}
+	reader.Header, reader.err = ReadHeader(&reader)
+	if reader.err == nil {
+		reader.err = reader.readConstants()
+	}
+	return reader
 }
 
-//ReadInt64 reads an int64.
+//Err returns the error from reading the header and the constants of the stream, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+//ReadByte reads a byte.
+func (r *Reader) ReadByte() (byte, error) {
+	b, err := r.Reader.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+//Read reads into the buffer.
+func (r *Reader) Read(buffer []byte) (int, error) {
+	n, err := r.Reader.Read(buffer)
+	r.offset += int64(n)
+	return n, err
+}
+
+//Offset returns the number of bytes that have been read.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+//ReadInt64 reads an int64 written as a zigzag varint.
 func (r *Reader) ReadInt64() (int64, error) {
-	var i int64
-	err := binary.Read(r, binary.LittleEndian, &i)
-	return i, err
+	i, err := binary.ReadVarint(r)
+	return i, eof(err)
+}
+
+//eof converts an EOF inside of an instruction into an io.ErrUnexpectedEOF.
+func eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+//wrap returns the error as an *Error for the instruction that begins at the offset.
+//Errors that already are an *Error are from a nested instruction and are returned as is.
+func wrap(offset int64, opcode byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Offset: offset, Opcode: opcode, Err: err}
+}
+
+//readLength reads a length or a count, which cannot be negative.
+func (r *Reader) readLength() (int64, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, eof(err)
+	}
+	if length > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid length %v", length)
+	}
+	return int64(length), nil
+}
+
+//readBytes reads a length followed by that many bytes.
+func (r *Reader) readBytes() ([]byte, error) {
+	length, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	return r.readN(length)
+}
+
+//readN reads the given number of bytes.
+func (r *Reader) readN(length int64) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid length %v", length)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, length))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+//readNumber reads the length of a number, which is negative for negative numbers, followed by its magnitude.
+func (r *Reader) readNumber() (*big.Int, error) {
+	length, err := r.ReadInt64()
+	if err != nil {
+		return nil, err
+	}
+	var negative = length < 0
+	if negative {
+		length = -length
+	}
+	data, err := r.readN(length)
+	if err != nil {
+		return nil, err
+	}
+	var number = new(big.Int).SetBytes(data)
+	if negative {
+		number.Neg(number)
+	}
+	return number, nil
+}
+
+//readLabel reads a label and maps it to the label of the target.
+func (r *Reader) readLabel() (usm.Label, error) {
+	label, err := r.ReadInt64()
+	if err != nil {
+		return 0, err
+	}
+	if label == 0 {
+		return 0, nil
+	}
+	if label < 0 || label > int64(len(r.labels)) || r.labels[label-1] == 0 {
+		return 0, fmt.Errorf("undefined label %v", label)
+	}
+	return r.labels[label-1], nil
+}
+
+//readRegister reads a register and returns what it refers to in the target.
+//Arguments are passed through unchanged.
+func (r *Reader) readRegister() (binding, error) {
+	register, err := r.ReadInt64()
+	if err != nil {
+		return binding{}, err
+	}
+	if register < 0 {
+		return binding{register: usm.Register(register)}, nil
+	}
+	var b, ok = r.registers[usm.Register(register)]
+	if !ok {
+		return binding{}, fmt.Errorf("undefined register %v", register)
+	}
+	return b, nil
+}
+
+//next returns the register that the next Var, Each or Range of the function creates.
+//Registers are created in order, so they must be read in order.
+func (r *Reader) next() int64 {
+	return int64(len(r.registers)) + 1
+}
+
+//bind assigns the value to the next register of an Each or a Range.
+func (r *Reader) bind(register int64, value usm.Value) {
+	r.registers[usm.Register(register)] = binding{value: value}
+}
+
+//readValues reads a count followed by that many values.
+func (r *Reader) readValues(t usm.Target) ([]usm.Value, error) {
+	length, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	var values []usm.Value
+	for i := int64(0); i < length; i++ {
+		value, err := r.ReadValue(t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+//readOperands reads n values.
+func (r *Reader) readOperands(t usm.Target, n int) ([]usm.Value, error) {
+	var operands = make([]usm.Value, n)
+	for i := range operands {
+		value, err := r.ReadValue(t)
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = value
+	}
+	return operands, nil
+}
+
+//unaries are the expressions that have one operand.
+var unaries = map[byte]func(t usm.Target, a usm.Value) usm.Value{
+	Pointer: func(t usm.Target, a usm.Value) usm.Value { return t.Pointer(a) },
+	Alloc:   func(t usm.Target, a usm.Value) usm.Value { return t.Alloc(a) },
+	Count:   func(t usm.Target, a usm.Value) usm.Value { return t.Count(a) },
+	Amount:  func(t usm.Target, a usm.Value) usm.Value { return t.Amount(a) },
+	Create:  func(t usm.Target, a usm.Value) usm.Value { return t.Create(a) },
+	Length:  func(t usm.Target, a usm.Value) usm.Value { return t.Length(a) },
+	Follow:  func(t usm.Target, a usm.Value) usm.Value { return t.Follow(a) },
+	Open:    func(t usm.Target, a usm.Value) usm.Value { return t.Open(a) },
+	Stat:    func(t usm.Target, a usm.Value) usm.Value { return t.Stat(a) },
+	Not:     func(t usm.Target, a usm.Value) usm.Value { return t.Not(a) },
+}
+
+//binaries are the expressions that have two operands.
+var binaries = map[byte]func(t usm.Target, a, b usm.Value) usm.Value{
+	Index:  func(t usm.Target, a, b usm.Value) usm.Value { return t.Index(a, b) },
+	Append: func(t usm.Target, a, b usm.Value) usm.Value { return t.Append(a, b) },
+	Lookup: func(t usm.Target, a, b usm.Value) usm.Value { return t.Lookup(a, b) },
+	Equals: func(t usm.Target, a, b usm.Value) usm.Value { return t.Equals(a, b) },
+	Symbol: func(t usm.Target, a, b usm.Value) usm.Value { return t.Symbol(a, b) },
+	Concat: func(t usm.Target, a, b usm.Value) usm.Value { return t.Concat(a, b) },
+	Read:   func(t usm.Target, a, b usm.Value) usm.Value { return t.Read(a, b) },
+	Send:   func(t usm.Target, a, b usm.Value) usm.Value { return t.Send(a, b) },
+	Add:    func(t usm.Target, a, b usm.Value) usm.Value { return t.Add(a, b) },
+	Sub:    func(t usm.Target, a, b usm.Value) usm.Value { return t.Sub(a, b) },
+	Mul:    func(t usm.Target, a, b usm.Value) usm.Value { return t.Mul(a, b) },
+	Div:    func(t usm.Target, a, b usm.Value) usm.Value { return t.Div(a, b) },
+	Mod:    func(t usm.Target, a, b usm.Value) usm.Value { return t.Mod(a, b) },
+	Pow:    func(t usm.Target, a, b usm.Value) usm.Value { return t.Pow(a, b) },
+	Less:   func(t usm.Target, a, b usm.Value) usm.Value { return t.Less(a, b) },
+	More:   func(t usm.Target, a, b usm.Value) usm.Value { return t.More(a, b) },
+	Same:   func(t usm.Target, a, b usm.Value) usm.Value { return t.Same(a, b) },
+	And:    func(t usm.Target, a, b usm.Value) usm.Value { return t.And(a, b) },
+	Or:     func(t usm.Target, a, b usm.Value) usm.Value { return t.Or(a, b) },
 }
 
 //ReadValue reads a value.
-func (r *Reader) ReadValue(t usm.Target) (usm.Value, error) {
-	for {
-		var opcode, err = r.ReadByte()
-		if err == io.EOF {
-			return nil, errors.New("bytecode.Reader.ReadBlock: unexpected eof")
-		} else if err != nil {
+func (r *Reader) ReadValue(t usm.Target) (value usm.Value, err error) {
+	var offset = r.offset
+	opcode, err := r.ReadByte()
+	if err != nil {
+		return nil, eof(err)
+	}
+	defer r.catch(offset, opcode, &err)
+	if err := r.enter(); err != nil {
+		return nil, wrap(offset, opcode, err)
+	}
+	defer r.leave()
+
+	value, err = r.readValue(opcode, t)
+	return value, wrap(offset, opcode, err)
+}
+
+func (r *Reader) readValue(opcode byte, t usm.Target) (usm.Value, error) {
+	if f, ok := unaries[opcode]; ok {
+		operands, err := r.readOperands(t, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(t, operands[0]), nil
+	}
+	if f, ok := binaries[opcode]; ok {
+		operands, err := r.readOperands(t, 2)
+		if err != nil {
 			return nil, err
 		}
+		return f(t, operands[0], operands[1]), nil
+	}
 
-		switch opcode {
-		case Nil:
-			return nil, nil
-		case String:
-			var length, err = r.ReadInt64()
-			if err != nil {
-				return nil, err
-			}
-			var buffer = make([]byte, length)
-			_, err = r.Read(buffer)
-			if err != nil {
-				return nil, err
-			}
-			return t.String(string(buffer)), nil
-		case Send:
-			var stream, err = r.ReadValue(t)
-			if err != nil {
-				return nil, err
-			}
-			value, err := r.ReadValue(t)
+	switch opcode {
+	case Nil:
+		return nil, nil
+	case String:
+		data, err := r.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return t.String(string(data)), nil
+	case Number:
+		number, err := r.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		return t.Number(number), nil
+	case Constant:
+		return r.readConstant(t)
+	case Bit:
+		bit, err := r.ReadByte()
+		if err != nil {
+			return nil, eof(err)
+		}
+		return t.Bit(bit != 0), nil
+	case Native:
+		data, err := r.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return t.Native(data), nil
+	case Get:
+		b, err := r.readRegister()
+		if err != nil {
+			return nil, err
+		}
+		if b.value != nil {
+			return b.value, nil
+		}
+		return t.Get(b.register), nil
+	case Bind:
+		label, err := r.readLabel()
+		if err != nil {
+			return nil, err
+		}
+		return t.Bind(label), nil
+	case Catch:
+		return t.Catch(), nil
+	case Errors:
+		return t.Errors(), nil
+	case Call, Fork:
+		label, err := r.readLabel()
+		if err != nil {
+			return nil, err
+		}
+		args, err := r.readValues(t)
+		if err != nil {
+			return nil, err
+		}
+		if opcode == Fork {
+			return t.Fork(label, args...), nil
+		}
+		return t.Call(label, args...), nil
+	case Array:
+		elements, err := r.readValues(t)
+		if err != nil {
+			return nil, err
+		}
+		return t.Array(elements...), nil
+	case Table:
+		length, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		var elements = make(map[usm.Value]usm.Value)
+		for i := int64(0); i < length; i++ {
+			operands, err := r.readOperands(t, 2)
 			if err != nil {
 				return nil, err
 			}
-			return t.Send(stream, value), nil
-		default:
-			panic("unimplemented: " + fmt.Sprint(opcode))
+			elements[operands[0]] = operands[1]
 		}
+		return t.Table(elements), nil
+	default:
+		return nil, errors.New("not a value")
 	}
 }
 
 //ReadStatement reads a usm statement from the reader.
 func (r *Reader) ReadStatement(opcode byte, t usm.Target) (err error) {
+	var offset = r.offset - 1
+	defer r.catch(offset, opcode, &err)
+	if err := r.enter(); err != nil {
+		return wrap(offset, opcode, err)
+	}
+	defer r.leave()
+
+	return wrap(offset, opcode, r.readStatement(opcode, t))
+}
+
+//MaxDepth is the deepest that values and statements may be nested in a stream.
+//Deeper streams are rejected, instead of overflowing the stack of the Reader.
+const MaxDepth = 10000
+
+//enter enters a nested value or statement.
+func (r *Reader) enter() error {
+	if r.depth >= MaxDepth {
+		return fmt.Errorf("nested deeper than %v", MaxDepth)
+	}
+	r.depth++
+	return nil
+}
+
+//leave leaves a nested value or statement.
+func (r *Reader) leave() {
+	r.depth--
+}
+
+//catch recovers from a panic of the target, which ill-formed bytecode causes by passing it values of the wrong kind,
+//and sets err to an *Error for the instruction that begins at the offset.
+//Must be deferred directly.
+func (r *Reader) catch(offset int64, opcode byte, err *error) {
+	if v := recover(); v != nil {
+		*err = &Error{Offset: offset, Opcode: opcode, Err: fmt.Errorf("rejected by the target: %v", v)}
+	}
+}
+
+func (r *Reader) readStatement(opcode byte, t usm.Target) (err error) {
 	switch opcode {
 	case Main:
-		t.Main(func() {
-			err = r.ReadBlock(t)
+		r.function(func() {
+			t.Main(func() {
+				err = r.ReadBlock(t)
+			})
 		})
+		return err
+
+	case Define:
+		arguments, err := r.readLength()
 		if err != nil {
 			return err
 		}
-	case Discard:
+
+		//The label is reserved before the body is read, so that the body is able to call itself.
+		var index = len(r.labels)
+		var expected usm.Label
+		if l, ok := t.(labeller); ok {
+			expected = l.NextLabel()
+		}
+		r.labels = append(r.labels, expected)
+
+		var label usm.Label
+		r.function(func() {
+			label = t.Define(int(arguments), func() {
+				err = r.ReadBlock(t)
+			})
+		})
+		r.labels[index] = label
+		if err == nil && expected != 0 && label != expected {
+			return fmt.Errorf("the target defined label %v instead of label %v", label, expected)
+		}
+		return err
+
+	case Var:
 		value, err := r.ReadValue(t)
 		if err != nil {
 			return err
 		}
-		t.Discard(value)
-	case Define:
-		var arguments, err = r.ReadInt64()
+		var register = t.Var(value)
+		r.registers[usm.Register(r.next())] = binding{register: register}
+		return nil
+
+	case Set:
+		b, err := r.readRegister()
 		if err != nil {
 			return err
 		}
-		t.Define(int(arguments), func() {
-			err = r.ReadBlock(t)
-		})
-	case JumpTo:
-		var label, err = r.ReadInt64()
+		if b.value != nil {
+			return errors.New("cannot set the register of a loop")
+		}
+		value, err := r.ReadValue(t)
 		if err != nil {
 			return err
 		}
-		length, err := r.ReadInt64()
+		t.Set(b.register, value)
+		return nil
+
+	case If:
+		length, err := r.readLength()
 		if err != nil {
 			return err
 		}
-		var arguments = make([]usm.Value, length)
-		for i := 0; i < int(length); i++ {
-			value, err := r.ReadValue(t)
+		otherwise, err := r.ReadByte()
+		if err != nil {
+			return eof(err)
+		}
+		var conditions []usm.Value
+		for i := int64(0); i <= length; i++ {
+			condition, err := r.ReadValue(t)
 			if err != nil {
 				return err
 			}
-			arguments[i] = value
+			conditions = append(conditions, condition)
+		}
+
+		//The blocks are read in the order that the target assembles them.
+		var block = func() {
+			if err == nil {
+				err = r.ReadBlock(t)
+			}
+		}
+		var chain = make([]usm.ElseIf, length)
+		for i := range chain {
+			chain[i] = usm.ElseIf{Bit: conditions[i+1], Block: block}
 		}
-		t.JumpTo(usm.Label(label), arguments...)
+		var last usm.Block
+		if otherwise != 0 {
+			last = block
+		}
+		t.If(conditions[0], block, chain, last)
+		return err
+
+	case Loop:
+		condition, err := r.ReadValue(t)
+		if err != nil {
+			return err
+		}
+		t.Loop(condition, func() {
+			err = r.ReadBlock(t)
+		})
+		return err
+
+	case Each:
+		array, err := r.ReadValue(t)
+		if err != nil {
+			return err
+		}
+		index, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+		value, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+		if index != r.next() || value != index+1 {
+			return fmt.Errorf("registers %v and %v are not the next registers", index, value)
+		}
+		t.Each(array, func(i usm.Number, v usm.Value) {
+			r.bind(index, i)
+			r.bind(value, v)
+			err = r.ReadBlock(t)
+		})
+		return err
+
+	case Range:
+		from, err := r.ReadValue(t)
+		if err != nil {
+			return err
+		}
+		relationship, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+		if relationship < -2 || relationship > 2 {
+			return fmt.Errorf("invalid relationship %v", relationship)
+		}
+		operands, err := r.readOperands(t, 2)
+		if err != nil {
+			return err
+		}
+		iterator, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+		if iterator != r.next() {
+			return fmt.Errorf("register %v is not the next register", iterator)
+		}
+		t.Range(from, int(relationship), operands[0], operands[1], func(i usm.Number) {
+			r.bind(iterator, i)
+			err = r.ReadBlock(t)
+		})
+		return err
+
+	case Break:
+		t.Break()
+		return nil
+
+	case Return:
+		value, err := r.ReadValue(t)
+		if err != nil {
+			return err
+		}
+		t.Return(value)
+		return nil
+
+	case JumpTo:
+		label, err := r.readLabel()
+		if err != nil {
+			return err
+		}
+		args, err := r.readValues(t)
+		if err != nil {
+			return err
+		}
+		t.JumpTo(label, args...)
+		return nil
+
+	case Discard, Throw, Delete:
+		value, err := r.ReadValue(t)
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case Discard:
+			t.Discard(value)
+		case Throw:
+			t.Throw(value)
+		case Delete:
+			t.Delete(nil, value)
+		}
+		return nil
+
+	case Seek, Change, Remove:
+		operands, err := r.readOperands(t, 2)
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case Seek:
+			t.Seek(operands[0], operands[1])
+		case Change:
+			t.Change(operands[0], operands[1])
+		case Remove:
+			t.Remove(operands[0], operands[1])
+		}
+		return nil
+
+	case Mutate, Insert, Modify:
+		operands, err := r.readOperands(t, 3)
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case Mutate:
+			t.Mutate(operands[0], operands[1], operands[2])
+		case Insert:
+			t.Insert(operands[0], operands[1], operands[2])
+		case Modify:
+			t.Modify(operands[0], operands[1], operands[2])
+		}
+		return nil
+
 	default:
-		panic("unimplemented: " + fmt.Sprint(opcode))
+		if int(opcode) < len(names) {
+			return errors.New("not a statement")
+		}
+		return errors.New("unknown opcode")
 	}
-	return
+}
+
+//function reads the body of a function, see WriteFunction.
+func (r *Reader) function(body func()) {
+	var registers = r.registers
+	r.registers = make(map[usm.Register]binding)
+	body()
+	r.registers = registers
 }
 
 //ReadBlock reads a block from the reader.
 func (r *Reader) ReadBlock(t usm.Target) error {
 	for {
 		var opcode, err = r.ReadByte()
-		if err == io.EOF {
-			return errors.New("bytecode.Reader.ReadBlock: unexpected eof")
-		} else if err != nil {
-			return err
+		if err != nil {
+			return eof(err)
 		}
 
 		if opcode == End {
@@ -138,6 +718,12 @@ func (r *Reader) ReadBlock(t usm.Target) error {
 
 //Target assembles the bytecode to the specified target.
 func (r Reader) Target(t usm.Target) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.registers == nil {
+		r.registers = make(map[usm.Register]binding)
+	}
 	for {
 		var opcode, err = r.ReadByte()
 		if err == io.EOF {
@@ -0,0 +1,266 @@
+package bytecode_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/bytecode"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//roundtrip builds the program with a bytecode.Target, decodes it into a runtime.Target and returns the output of running it.
+func roundtrip(t *testing.T, program func(c usm.Target)) string {
+	t.Helper()
+
+	var c bytecode.Target
+	program(&c)
+	var buffer bytes.Buffer
+	if _, err := c.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	var r runtime.Target
+	var output bytes.Buffer
+	r.Host.Stdout = &output
+	if err := bytecode.NewReader(&buffer).Target(&r); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+//digit returns the String of a number from 0 to 9.
+func digit(c usm.Target, n usm.Number) usm.String {
+	return c.Index(c.Array(
+		c.String("0"), c.String("1"), c.String("2"), c.String("3"), c.String("4"),
+		c.String("5"), c.String("6"), c.String("7"), c.String("8"), c.String("9"),
+	), n)
+}
+
+func TestRecursion(t *testing.T) {
+	var output = roundtrip(t, func(c usm.Target) {
+		var one = c.Number(big.NewInt(1))
+		c.Main(func() {
+			//Labels are reserved before the body, so the first function is able to call itself as label 1.
+			const factorial = 1
+			c.Define(1, func() {
+				c.If(c.Less(c.Get(usm.Arg(0)), c.Number(big.NewInt(2))), func() {
+					c.Return(one)
+				}, nil, nil)
+				c.Return(c.Mul(c.Get(usm.Arg(0)), c.Call(factorial, c.Sub(c.Get(usm.Arg(0)), one))))
+			})
+			var result = c.Var(c.Call(factorial, c.Number(big.NewInt(5))))
+			c.Discard(c.Send(nil, digit(c, c.Div(c.Get(result), c.Number(big.NewInt(100))))))
+			c.Discard(c.Send(nil, digit(c, c.Mod(c.Div(c.Get(result), c.Number(big.NewInt(10))), c.Number(big.NewInt(10))))))
+			c.Discard(c.Send(nil, digit(c, c.Mod(c.Get(result), c.Number(big.NewInt(10))))))
+		})
+	})
+	if output != "120" {
+		t.Fatalf("expected 120, got %q", output)
+	}
+}
+
+func TestNestedFunctions(t *testing.T) {
+	var output = roundtrip(t, func(c usm.Target) {
+		c.Main(func() {
+			//The outer function is label 1 and the inner function is label 2.
+			const outer = 1
+			c.Define(1, func() {
+				var inner = c.Define(1, func() {
+					c.If(c.More(c.Get(usm.Arg(0)), c.Number(big.NewInt(0))), func() {
+						c.Discard(c.Send(nil, c.String("i")))
+						c.JumpTo(outer, c.Sub(c.Get(usm.Arg(0)), c.Number(big.NewInt(1))))
+					}, nil, nil)
+				})
+				c.Discard(c.Send(nil, c.String("o")))
+				c.JumpTo(inner, c.Get(usm.Arg(0)))
+			})
+			c.JumpTo(outer, c.Number(big.NewInt(2)))
+		})
+	})
+	if output != "oioio" {
+		t.Fatalf("expected oioio, got %q", output)
+	}
+}
+
+func TestDeleteAndStat(t *testing.T) {
+	var output = roundtrip(t, func(c usm.Target) {
+		c.Main(func() {
+			c.Delete(nil, c.Array(c.String("x")))
+			c.Discard(c.Stat(c.Open(c.String("stdout"))))
+			c.Discard(c.Send(nil, c.Catch()))
+		})
+	})
+	if output != "stream is not a file" {
+		t.Fatalf("expected the Stat error, got %q", output)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var c bytecode.Target
+	c.Main(func() {
+		var f = c.Define(1, func() {
+			c.Return(c.Add(c.Get(usm.Arg(0)), c.Number(big.NewInt(-3))))
+		})
+		c.Discard(c.Send(nil, c.Concat(c.String("a"), c.Call(f, c.Number(big.NewInt(1))))))
+	})
+	var buffer bytes.Buffer
+	c.WriteTo(&buffer)
+	var data = buffer.Bytes()
+
+	for i := 0; i < len(data); i++ {
+		var r runtime.Target
+		var err = bytecode.NewReader(bytes.NewReader(data[:i])).Target(&r)
+		if err == nil {
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "bytecode: ") {
+			t.Fatalf("prefix %v: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	var nested = func(opcode byte) []byte {
+		return bytes.Repeat([]byte{opcode}, bytecode.MaxDepth+1)
+	}
+	var tests = []struct {
+		name    string
+		program []byte
+	}{
+		{"unknown opcode", []byte{200}},
+		{"value as a statement", []byte{bytecode.Add}},
+		{"nil operand", []byte{bytecode.Main, bytecode.Discard, bytecode.Nil, bytecode.End}},
+		{"undefined register", []byte{bytecode.Main, bytecode.Discard, bytecode.Get, 2, bytecode.End}},
+		{"undefined label", []byte{bytecode.Main, bytecode.JumpTo, 2, 0, bytecode.End}},
+		{"each registers", []byte{bytecode.Main, bytecode.Each, bytecode.Array, 0, 4, 6, bytecode.End, bytecode.End}},
+		{"each value register", []byte{bytecode.Main, bytecode.Each, bytecode.Array, 0, 2, 2, bytecode.End, bytecode.End}},
+		{"range register", []byte{bytecode.Main, bytecode.Range,
+			bytecode.Count, bytecode.Array, 0, 0, bytecode.Count, bytecode.Array, 0, bytecode.Count, bytecode.Array, 0,
+			4, bytecode.End, bytecode.End}},
+		{"nested values", append([]byte{bytecode.Main, bytecode.Discard}, nested(bytecode.Not)...)},
+		{"nested blocks", nested(bytecode.Main)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stream bytes.Buffer
+			bytecode.Header{Version: bytecode.Version}.WriteTo(&stream)
+			stream.WriteByte(0) //No constants.
+			stream.Write(test.program)
+
+			var r runtime.Target
+			var err = bytecode.NewReader(&stream).Target(&r)
+			var e *bytecode.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected a *bytecode.Error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDisassembleSpelling(t *testing.T) {
+	var c bytecode.Target
+	c.Main(func() {
+		c.Var(c.Create(c.Number(big.NewInt(3))))
+		c.Var(c.String("abc"))
+	})
+	var buffer, text bytes.Buffer
+	if _, err := c.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if err := bytecode.Disassemble(&text, &buffer); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"VAR r1 <STRING 3>", `VAR r2 "abc"`} {
+		if !strings.Contains(text.String(), expected) {
+			t.Fatalf("expected %q in:\n%v", expected, text.String())
+		}
+	}
+}
+
+func TestDisassembleElse(t *testing.T) {
+	var c bytecode.Target
+	c.Main(func() {
+		var discard = func() { c.Discard(c.Send(nil, c.String(""))) }
+		c.If(c.Bit(true), discard, []usm.ElseIf{{Bit: c.Bit(false), Block: discard}}, discard)
+	})
+	var buffer, text bytes.Buffer
+	if _, err := c.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if err := bytecode.Disassemble(&text, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	var elses int
+	for _, line := range strings.Split(text.String(), "\n") {
+		if strings.Contains(line, "ELSE") {
+			elses++
+			if strings.TrimSpace(line)[:4] != "ELSE" {
+				t.Fatalf("expected ELSE without an offset, got %q", line)
+			}
+		}
+	}
+	if elses != 2 {
+		t.Fatalf("expected 2 ELSE lines in:\n%v", text.String())
+	}
+}
+
+func TestLiteralNames(t *testing.T) {
+	var stream bytes.Buffer
+	bytecode.Header{Version: bytecode.Version}.WriteTo(&stream)
+	stream.WriteByte(0) //No constants.
+	stream.Write([]byte{bytecode.Main, bytecode.Discard, bytecode.Bit})
+
+	var r runtime.Target
+	var err = bytecode.NewReader(&stream).Target(&r)
+	if err == nil || !strings.Contains(err.Error(), "BIT LITERAL") {
+		t.Fatalf("expected an error for the BIT LITERAL, got %v", err)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	var large, _ = new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+	var numbers = []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(-1),
+		big.NewInt(63), big.NewInt(64), big.NewInt(-64), big.NewInt(-65),
+		big.NewInt(255), big.NewInt(256), big.NewInt(-256),
+		big.NewInt(math.MaxInt64), big.NewInt(math.MinInt64),
+		new(big.Int).Lsh(big.NewInt(1), 64), new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64)),
+		large, new(big.Int).Neg(large),
+	}
+
+	var c bytecode.Target
+	var labels []usm.Label
+	for _, n := range numbers {
+		var n = n
+		labels = append(labels, c.Define(0, func() {
+			c.Return(c.Number(n))
+		}))
+	}
+	var buffer bytes.Buffer
+	if _, err := c.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	var r runtime.Target
+	if err := bytecode.NewReader(&buffer).Target(&r); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range numbers {
+		result, err := r.NewRuntime().Invoke(labels[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.(*big.Int).Cmp(n) != 0 {
+			t.Fatalf("expected %v, got %v", n, result)
+		}
+	}
+}
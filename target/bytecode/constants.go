@@ -0,0 +1,92 @@
+package bytecode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//The constant section holds the strings and numbers of a program, each one only once.
+//It begins with the number of constants, followed by each constant encoded as a String or Number value.
+//Instructions refer to a constant with the Constant opcode followed by its index in the section.
+
+//constant interns the encoded String or Number and returns a reference to it.
+func (t *Target) constant(encoded []byte) usm.Value {
+	if t.interned == nil {
+		t.interned = make(map[string]int)
+	}
+	index, ok := t.interned[string(encoded)]
+	if !ok {
+		index = len(t.interned)
+		t.interned[string(encoded)] = index
+		t.constants.Write(encoded)
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(Constant) //Header
+	writeLength(&b, index)
+	return NewValue(b.Bytes())
+}
+
+//writeConstants writes the constant section.
+func (t *Target) writeConstants(writer io.Writer) (int64, error) {
+	var b bytes.Buffer
+	writeLength(&b, len(t.interned))
+	b.Write(t.constants.Bytes())
+	n, err := writer.Write(b.Bytes())
+	return int64(n), err
+}
+
+//readConstants reads the constant section.
+func (r *Reader) readConstants() error {
+	count, err := r.readLength()
+	if err != nil {
+		return &Error{Offset: r.offset, Opcode: Constant, Err: err}
+	}
+	for i := int64(0); i < count; i++ {
+		var offset = r.offset
+		opcode, err := r.ReadByte()
+		if err != nil {
+			return &Error{Offset: offset, Opcode: Constant, Err: eof(err)}
+		}
+
+		switch opcode {
+		case String:
+			data, err := r.readBytes()
+			if err != nil {
+				return wrap(offset, opcode, err)
+			}
+			r.constants = append(r.constants, string(data))
+		case Number:
+			number, err := r.readNumber()
+			if err != nil {
+				return wrap(offset, opcode, err)
+			}
+			r.constants = append(r.constants, number)
+		default:
+			return wrap(offset, opcode, errors.New("not a constant"))
+		}
+	}
+	return nil
+}
+
+//readConstant reads the index of a constant and returns the constant as a value of the target.
+func (r *Reader) readConstant(t usm.Target) (usm.Value, error) {
+	index, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if index >= int64(len(r.constants)) {
+		return nil, fmt.Errorf("undefined constant %v", index)
+	}
+	switch constant := r.constants[index].(type) {
+	case string:
+		return t.String(constant), nil
+	default:
+		return t.Number(new(big.Int).Set(constant.(*big.Int))), nil
+	}
+}
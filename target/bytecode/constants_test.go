@@ -0,0 +1,43 @@
+package bytecode_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/bytecode"
+)
+
+func TestConstants(t *testing.T) {
+	var large, _ = new(big.Int).SetString("98765432109876543210987654321", 10)
+
+	var program = func(c usm.Target) {
+		c.Main(func() {
+			for i := 0; i < 10; i++ {
+				c.Discard(c.Send(nil, c.String("message;")))
+				c.Var(c.Number(new(big.Int).Set(large)))
+			}
+			//A String and a Number with the same bytes are different constants.
+			c.Discard(c.Send(nil, c.String("\x05")))
+			c.Discard(c.Send(nil, digit(c, c.Number(big.NewInt(5)))))
+		})
+	}
+
+	var c bytecode.Target
+	program(&c)
+	var buffer bytes.Buffer
+	if _, err := c.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(buffer.Bytes(), []byte("message;")); n != 1 {
+		t.Fatalf("expected the string once, found it %v times", n)
+	}
+	if n := bytes.Count(buffer.Bytes(), large.Bytes()); n != 1 {
+		t.Fatalf("expected the number once, found it %v times", n)
+	}
+
+	if output, expected := roundtrip(t, program), string(bytes.Repeat([]byte("message;"), 10))+"\x055"; output != expected {
+		t.Fatalf("expected %q, got %q", expected, output)
+	}
+}
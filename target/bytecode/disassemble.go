@@ -0,0 +1,447 @@
+package bytecode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+//Disassemble reads a bytecode stream and writes it as indented usm assembly, using the mnemonics of docs/spec.txt.
+//Each line begins with the byte offset of its instruction. Labels are written as $1, $2 ... in the order they are defined,
+//the registers of a function as r1, r2 ... and its arguments as a0, a1 ...
+//Constants are written in place as literals.
+func Disassemble(w io.Writer, r io.Reader) error {
+	var reader = NewReader(r)
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	var d = disassembler{Reader: &reader, Writer: bufio.NewWriter(w)}
+	d.comment("usm bytecode version %v, flags %#x", reader.Header.Version, uint16(reader.Header.Flags))
+	for i, constant := range reader.constants {
+		d.comment("#%v = %v", i, literal(constant))
+	}
+
+	for {
+		var offset = reader.offset
+		var opcode, err = reader.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := d.statement(offset, opcode); err != nil {
+			d.Flush()
+			return err
+		}
+	}
+	return d.Flush()
+}
+
+//disassembler writes the instructions read by a Reader.
+type disassembler struct {
+	*Reader
+	*bufio.Writer
+
+	tabs int
+
+	//labels is the number of functions defined, registers the number of registers in the current function.
+	labels    int
+	registers int64
+}
+
+//line writes a line for the instruction that begins at the offset.
+func (d *disassembler) line(offset int64, format string, args ...interface{}) {
+	d.indented(fmt.Sprint(offset), format, args...)
+}
+
+//clause writes a line that continues the instruction before it, such as an ELSE, so it has no offset of its own.
+func (d *disassembler) clause(format string, args ...interface{}) {
+	d.indented("", format, args...)
+}
+
+//indented writes a line after the prefix and the indentation.
+func (d *disassembler) indented(prefix string, format string, args ...interface{}) {
+	fmt.Fprintf(d, "%6v  %v", prefix, strings.Repeat("\t", d.tabs))
+	fmt.Fprintf(d, format, args...)
+	d.WriteByte('\n')
+}
+
+//comment writes a line that is not an instruction.
+func (d *disassembler) comment(format string, args ...interface{}) {
+	fmt.Fprintf(d, "%6v  ; ", "")
+	fmt.Fprintf(d, format, args...)
+	d.WriteByte('\n')
+}
+
+//literal returns a constant as a usm literal.
+func literal(constant interface{}) string {
+	if s, ok := constant.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return constant.(*big.Int).String()
+}
+
+//register returns the name of the register.
+func register(r int64) string {
+	if r < 0 {
+		return fmt.Sprintf("a%v", -r-1)
+	}
+	return fmt.Sprintf("r%v", r)
+}
+
+//mnemonic returns the expression of the opcode with the operands, as usm text.
+func mnemonic(opcode byte, operands ...string) string {
+	return "<" + strings.Join(append([]string{Name(opcode)}, operands...), " ") + ">"
+}
+
+//values reads n values.
+func (d *disassembler) values(n int64) ([]string, error) {
+	var values []string
+	for i := int64(0); i < n; i++ {
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+//list reads a count followed by that many values.
+func (d *disassembler) list() ([]string, error) {
+	n, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	return d.values(n)
+}
+
+//value reads a value.
+func (d *disassembler) value() (string, error) {
+	var offset = d.offset
+	var opcode, err = d.ReadByte()
+	if err != nil {
+		return "", eof(err)
+	}
+	if err := d.enter(); err != nil {
+		return "", wrap(offset, opcode, err)
+	}
+	defer d.leave()
+
+	value, err := d.readValue(opcode)
+	return value, wrap(offset, opcode, err)
+}
+
+func (d *disassembler) readValue(opcode byte) (string, error) {
+	if _, ok := unaries[opcode]; ok {
+		operands, err := d.values(1)
+		return mnemonic(opcode, operands...), err
+	}
+	if _, ok := binaries[opcode]; ok {
+		operands, err := d.values(2)
+		return mnemonic(opcode, operands...), err
+	}
+
+	switch opcode {
+	case Nil:
+		return "NIL", nil
+	case String:
+		data, err := d.readBytes()
+		return fmt.Sprintf("%q", data), err
+	case Number:
+		number, err := d.readNumber()
+		if err != nil {
+			return "", err
+		}
+		return number.String(), nil
+	case Constant:
+		index, err := d.readLength()
+		if err != nil {
+			return "", err
+		}
+		if index >= int64(len(d.constants)) {
+			return "", fmt.Errorf("undefined constant %v", index)
+		}
+		return literal(d.constants[index]), nil
+	case Bit:
+		bit, err := d.ReadByte()
+		if err != nil {
+			return "", eof(err)
+		}
+		if bit != 0 {
+			return "true", nil
+		}
+		return "false", nil
+	case Native:
+		data, err := d.readBytes()
+		return mnemonic(opcode, fmt.Sprintf("%q", data)), err
+	case Get:
+		r, err := d.ReadInt64()
+		return mnemonic(opcode, register(r)), err
+	case Bind:
+		label, err := d.ReadInt64()
+		return mnemonic(opcode, fmt.Sprintf("$%v", label)), err
+	case Catch, Errors:
+		return mnemonic(opcode), nil
+	case Call, Fork:
+		label, err := d.ReadInt64()
+		if err != nil {
+			return "", err
+		}
+		args, err := d.list()
+		return mnemonic(opcode, append([]string{fmt.Sprintf("$%v", label)}, args...)...), err
+	case Array:
+		elements, err := d.list()
+		return mnemonic(opcode, elements...), err
+	case Table:
+		n, err := d.readLength()
+		if err != nil {
+			return "", err
+		}
+		var elements []string
+		for i := int64(0); i < n; i++ {
+			pair, err := d.values(2)
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, pair...)
+		}
+		return mnemonic(opcode, elements...), nil
+	default:
+		if int(opcode) < len(names) {
+			return "", errors.New("not a value")
+		}
+		return "", errors.New("unknown opcode")
+	}
+}
+
+//operands is the number of operands of the statements that are an opcode followed by values.
+var operands = map[byte]int64{
+	Discard: 1, Throw: 1, Delete: 1,
+	Seek: 2, Change: 2, Remove: 2,
+	Mutate: 3, Insert: 3, Modify: 3,
+}
+
+//relationships are the symbols of the relationships of a Range.
+var relationships = map[int64]string{-2: "<", -1: "<=", 0: "=", 1: ">=", 2: ">"}
+
+//statement writes the statement with the opcode that begins at the offset.
+func (d *disassembler) statement(offset int64, opcode byte) error {
+	if err := d.enter(); err != nil {
+		return wrap(offset, opcode, err)
+	}
+	defer d.leave()
+
+	return wrap(offset, opcode, d.readStatement(offset, opcode))
+}
+
+func (d *disassembler) readStatement(offset int64, opcode byte) error {
+	var name = Name(opcode)
+
+	if n, ok := operands[opcode]; ok {
+		values, err := d.values(n)
+		if err != nil {
+			return err
+		}
+		d.line(offset, "%v %v", name, strings.Join(values, " "))
+		return nil
+	}
+
+	switch opcode {
+	case Main:
+		d.line(offset, "%v {", name)
+		return d.function()
+
+	case Define:
+		arguments, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.labels++
+		d.line(offset, "%v $%v %v {", name, d.labels, arguments)
+		return d.function()
+
+	case Var:
+		value, err := d.value()
+		if err != nil {
+			return err
+		}
+		d.registers++
+		d.line(offset, "%v %v %v", name, register(d.registers), value)
+		return nil
+
+	case Set:
+		r, err := d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		value, err := d.value()
+		if err != nil {
+			return err
+		}
+		d.line(offset, "%v %v %v", name, register(r), value)
+		return nil
+
+	case Return:
+		value, err := d.value()
+		if err != nil {
+			return err
+		}
+		if value == "NIL" {
+			d.line(offset, "%v", name)
+		} else {
+			d.line(offset, "%v %v", name, value)
+		}
+		return nil
+
+	case Break:
+		d.line(offset, "%v", name)
+		return nil
+
+	case JumpTo:
+		label, err := d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		args, err := d.list()
+		if err != nil {
+			return err
+		}
+		d.line(offset, "%v", strings.Join(append([]string{name, fmt.Sprintf("$%v", label)}, args...), " "))
+		return nil
+
+	case If:
+		chain, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		last, err := d.ReadByte()
+		if err != nil {
+			return eof(err)
+		}
+		conditions, err := d.values(chain + 1)
+		if err != nil {
+			return err
+		}
+
+		d.line(offset, "%v %v {", name, conditions[0])
+		if err := d.block(); err != nil {
+			return err
+		}
+		for _, condition := range conditions[1:] {
+			d.clause("ELSE %v {", condition)
+			if err := d.block(); err != nil {
+				return err
+			}
+		}
+		if last != 0 {
+			d.clause("ELSE {")
+			return d.block()
+		}
+		return nil
+
+	case Loop:
+		condition, err := d.value()
+		if err != nil {
+			return err
+		}
+		if condition == "NIL" {
+			d.line(offset, "%v {", name)
+		} else {
+			d.line(offset, "%v %v {", name, condition)
+		}
+		return d.block()
+
+	case Each:
+		array, err := d.value()
+		if err != nil {
+			return err
+		}
+		index, err := d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		value, err := d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		d.allocate(index, value)
+		d.line(offset, "%v %v %v %v {", name, array, register(index), register(value))
+		return d.block()
+
+	case Range:
+		from, err := d.value()
+		if err != nil {
+			return err
+		}
+		relationship, err := d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		symbol, ok := relationships[relationship]
+		if !ok {
+			return fmt.Errorf("invalid relationship %v", relationship)
+		}
+		values, err := d.values(2)
+		if err != nil {
+			return err
+		}
+		iterator, err := d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		d.allocate(iterator)
+		d.line(offset, "%v %v %v %v %v %v {", name, from, symbol, values[0], values[1], register(iterator))
+		return d.block()
+
+	default:
+		if int(opcode) < len(names) {
+			return errors.New("not a statement")
+		}
+		return errors.New("unknown opcode")
+	}
+}
+
+//allocate counts the registers written by an Each or Range, so that the following Var statements are numbered after them.
+func (d *disassembler) allocate(registers ...int64) {
+	for _, r := range registers {
+		if r > d.registers {
+			d.registers = r
+		}
+	}
+}
+
+//function writes the body of a function, see WriteFunction.
+func (d *disassembler) function() error {
+	var registers = d.registers
+	d.registers = 0
+	err := d.block()
+	d.registers = registers
+	return err
+}
+
+//block writes the statements of a block, indented, followed by its closing brace.
+func (d *disassembler) block() error {
+	d.tabs++
+	for {
+		var offset = d.offset
+		var opcode, err = d.ReadByte()
+		if err != nil {
+			return eof(err)
+		}
+
+		if opcode == End {
+			d.tabs--
+			d.line(offset, "}")
+			return nil
+		}
+
+		if err := d.statement(offset, opcode); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package bytecode
+
+//FixedWidth sets whether integers are written as 8 bytes instead of varints, see fixedWidth.
+func FixedWidth(fixed bool) {
+	fixedWidth = fixed
+}
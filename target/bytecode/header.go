@@ -0,0 +1,88 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//Every bytecode stream begins with a header made of the Magic bytes, followed by the format Version
+//and the Flags of the stream, each as a little-endian uint16.
+//
+//The Version is incremented whenever the encoding of an existing opcode changes or an opcode is added,
+//a Reader only reads streams of its own Version. Flags mark optional features that a stream uses,
+//a Reader rejects streams with flags that it does not know, so they never read a stream incorrectly.
+//Both are reported by a *VersionError.
+//
+//The header is followed by the constant section and then by the statements of the program.
+
+//Magic identifies a usm bytecode stream.
+const Magic = "\x7fusm"
+
+//Version is the version of the bytecode format written by Target and read by Reader.
+const Version = 4
+
+//Flags are the optional features used by a bytecode stream.
+type Flags uint16
+
+//KnownFlags are the flags understood by this version of the Reader.
+const KnownFlags Flags = 0
+
+//ErrNotBytecode is returned when a stream does not begin with a bytecode header.
+var ErrNotBytecode = errors.New("bytecode: not a usm bytecode stream")
+
+//VersionError is returned when a stream was written with a version or flags that the Reader does not support.
+type VersionError struct {
+	Version uint16
+	Flags   Flags
+}
+
+func (e *VersionError) Error() string {
+	if e.Version != Version {
+		return fmt.Sprintf("bytecode: unsupported version %v, expected version %v", e.Version, Version)
+	}
+	return fmt.Sprintf("bytecode: unsupported flags %#x", uint16(e.Flags&^KnownFlags))
+}
+
+//Header is the header of a bytecode stream.
+type Header struct {
+	Version uint16
+	Flags   Flags
+}
+
+//headerSize is the size of an encoded header in bytes.
+const headerSize = len(Magic) + 4
+
+//WriteTo writes the header.
+func (h Header) WriteTo(w io.Writer) (int64, error) {
+	var header = make([]byte, 0, headerSize)
+	header = append(header, Magic...)
+	header = append(header, byte(h.Version), byte(h.Version>>8))
+	header = append(header, byte(h.Flags), byte(h.Flags>>8))
+	n, err := w.Write(header)
+	return int64(n), err
+}
+
+//ReadHeader reads and validates the header of a bytecode stream.
+func ReadHeader(r io.Reader) (Header, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return Header{}, ErrNotBytecode
+		}
+		return Header{}, err
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return Header{}, ErrNotBytecode
+	}
+
+	var h = Header{
+		Version: binary.LittleEndian.Uint16(header[len(Magic):]),
+		Flags:   Flags(binary.LittleEndian.Uint16(header[len(Magic)+2:])),
+	}
+	if h.Version != Version || h.Flags&^KnownFlags != 0 {
+		return h, &VersionError{Version: h.Version, Flags: h.Flags}
+	}
+	return h, nil
+}
@@ -0,0 +1,70 @@
+package bytecode_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/qlova/usm/target/bytecode"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestHeader(t *testing.T) {
+	//stream returns an empty program with the header.
+	var stream = func(header bytecode.Header) string {
+		var b bytes.Buffer
+		header.WriteTo(&b)
+		b.WriteByte(0) //No constants.
+		return b.String()
+	}
+
+	var tests = []struct {
+		name    string
+		stream  string
+		version bool
+		flags   bool
+	}{
+		{"empty", "", false, false},
+		{"short", bytecode.Magic[:2], false, false},
+		{"magic", "\x7fELF\x04\x00\x00\x00\x00", false, false},
+		{"old version", stream(bytecode.Header{Version: bytecode.Version - 1}), true, false},
+		{"new version", stream(bytecode.Header{Version: bytecode.Version + 1}), true, false},
+		{"unknown flags", stream(bytecode.Header{Version: bytecode.Version, Flags: 1 << 15}), false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r runtime.Target
+			var err = bytecode.NewReader(strings.NewReader(test.stream)).Target(&r)
+
+			var version *bytecode.VersionError
+			switch {
+			case test.version:
+				if !errors.As(err, &version) || version.Version == bytecode.Version {
+					t.Fatalf("expected an unsupported version, got %v", err)
+				}
+			case test.flags:
+				if !errors.As(err, &version) || version.Version != bytecode.Version || version.Flags == 0 {
+					t.Fatalf("expected unsupported flags, got %v", err)
+				}
+				if !strings.Contains(err.Error(), "0x8000") {
+					t.Fatalf("expected the unknown flags in %q", err)
+				}
+			default:
+				if !errors.Is(err, bytecode.ErrNotBytecode) {
+					t.Fatalf("expected ErrNotBytecode, got %v", err)
+				}
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var reader = bytecode.NewReader(strings.NewReader(stream(bytecode.Header{Version: bytecode.Version})))
+		if err := reader.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if reader.Header != (bytecode.Header{Version: bytecode.Version}) {
+			t.Fatalf("got %+v", reader.Header)
+		}
+	})
+}
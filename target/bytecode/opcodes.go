@@ -1,5 +1,7 @@
 package bytecode
 
+import "fmt"
+
 //This is a list of all opcodes for usm bytecode.
 const (
 	Nil = iota
@@ -90,4 +92,40 @@ const (
 	And
 	Or
 	Not
+
+	//These opcodes were added after the original table, so they follow it to keep the existing opcodes stable.
+
+	Range
+	Errors
+	Native
+	Constant
 )
+
+//names are the mnemonics of the opcodes as spelled in docs/spec.txt (so Create is STRING), literals are named after their type.
+var names = [...]string{
+	Nil: "NIL", End: "END",
+	Var: "VAR", Set: "SET", Discard: "DISCARD", Main: "MAIN",
+	If: "IF", Loop: "LOOP", Each: "EACH", Break: "BREAK",
+	Define: "DEFINE", Return: "RETURN", JumpTo: "JUMPTO",
+	Throw: "THROW", Seek: "SEEK", Delete: "DELETE",
+	Change: "CHANGE", Mutate: "MUTATE", Insert: "INSERT", Remove: "REMOVE", Modify: "MODIFY",
+	Number: "NUMBER LITERAL", String: "STRING LITERAL", Bit: "BIT LITERAL",
+	Get: "GET", Bind: "BIND", Catch: "CATCH", Call: "CALL", Fork: "FORK", Pointer: "POINTER",
+	Array: "ARRAY", Alloc: "ALLOC", Count: "COUNT", Index: "INDEX", Append: "APPEND",
+	Table: "TABLE", Amount: "AMOUNT", Lookup: "LOOKUP",
+	Create: "STRING", Equals: "EQUALS", Length: "LENGTH", Symbol: "SYMBOL", Concat: "CONCAT", Follow: "FOLLOW",
+	Open: "OPEN", Stat: "STAT", Read: "READ", Send: "SEND",
+	Add: "ADD", Sub: "SUB", Mul: "MUL", Div: "DIV", Mod: "MOD", Pow: "POW",
+	Less: "LESS", More: "MORE", Same: "SAME",
+	And: "AND", Or: "OR", Not: "NOT",
+	Range: "RANGE", Errors: "ERRORS", Native: "NATIVE",
+	Constant: "CONSTANT",
+}
+
+//Name returns the mnemonic of the opcode.
+func Name(opcode byte) string {
+	if int(opcode) < len(names) {
+		return names[opcode]
+	}
+	return fmt.Sprintf("OPCODE(%d)", opcode)
+}
@@ -5,16 +5,32 @@ import (
 	"encoding/binary"
 	"io"
 	"math/big"
+	"sort"
 
 	"github.com/qlova/usm"
 	"github.com/qlova/usm/template"
 )
 
+//Expression is the encoding of a bytecode expression.
+type Expression []byte
+
+//Value is a bytecode usm.Value, see runtime.Value.
+type Value = *Expression
+
+//NewValue returns the Expression as a Value.
+func NewValue(e Expression) Value {
+	return &e
+}
+
 //Target is a Bytecode target for u
 type Target struct {
 	template.Target
 	labels    usm.Label
 	registers usm.Register
+
+	//constants holds the encoded constants, interned maps an encoded constant to its index.
+	constants bytes.Buffer
+	interned  map[string]int
 }
 
 //WriteBlock writes a block.
@@ -23,9 +39,26 @@ func (t *Target) WriteBlock(block usm.Block) {
 	t.WriteByte(End)
 }
 
-//WriteInt64 writes a int64 to the target.
+//WriteFunction writes a block with its own registers, see usm.Register.
+func (t *Target) WriteFunction(block usm.Block) {
+	var registers = t.registers
+	t.registers = 0
+	t.WriteBlock(block)
+	t.registers = registers
+}
+
+//fixedWidth makes the integers be written as 8 bytes, as they were before they were varints.
+//It is only set by the benchmarks, to compare the size of the two encodings.
+var fixedWidth bool
+
+//writeInt64 writes a int64 as a zigzag varint, so that small values of either sign take few bytes.
 func writeInt64(w io.Writer, i int64) {
-	binary.Write(w, binary.LittleEndian, i)
+	if fixedWidth {
+		binary.Write(w, binary.LittleEndian, i)
+		return
+	}
+	var buffer [binary.MaxVarintLen64]byte
+	w.Write(buffer[:binary.PutVarint(buffer[:], i)])
 }
 
 //WriteInt64 writes a int64 to the target.
@@ -33,20 +66,137 @@ func (t *Target) WriteInt64(i int64) {
 	writeInt64(t, i)
 }
 
+//writeLength writes a length or a count as a varint.
+func writeLength(w io.Writer, length int) {
+	if fixedWidth {
+		binary.Write(w, binary.LittleEndian, int64(length))
+		return
+	}
+	var buffer [binary.MaxVarintLen64]byte
+	w.Write(buffer[:binary.PutUvarint(buffer[:], uint64(length))])
+}
+
+//WriteLength writes a length or a count to the target.
+func (t *Target) WriteLength(length int) {
+	writeLength(t, length)
+}
+
+//writeValue writes a value, nil values are written as Nil.
+func writeValue(b *bytes.Buffer, v usm.Value) {
+	if v == nil {
+		b.WriteByte(Nil)
+		return
+	}
+	b.Write(*v.(Value))
+}
+
 //WriteValue writes a value to the target.
 func (t *Target) WriteValue(v usm.Value) {
-	t.Write(v.([]byte))
+	writeValue(&t.Buffer, v)
 }
 
-//WriteTo writes the target.
+//statement writes a statement made of the opcode followed by its operands.
+func (t *Target) statement(opcode byte, operands ...usm.Value) {
+	t.WriteByte(opcode)
+	for _, operand := range operands {
+		t.WriteValue(operand)
+	}
+}
+
+//expression returns an expression made of the opcode followed by its operands.
+func expression(opcode byte, operands ...usm.Value) usm.Value {
+	var b bytes.Buffer
+	b.WriteByte(opcode) //Header
+	for _, operand := range operands {
+		writeValue(&b, operand)
+	}
+	return NewValue(b.Bytes())
+}
+
+//call returns a Call or Fork expression of the label with the variadic arguments.
+func call(opcode byte, label usm.Label, args []usm.Value) usm.Value {
+	var b bytes.Buffer
+	b.WriteByte(opcode) //Header
+	writeInt64(&b, int64(label))
+	writeLength(&b, len(args))
+	for _, arg := range args {
+		writeValue(&b, arg)
+	}
+	return NewValue(b.Bytes())
+}
+
+//register reserves a new register in the current function.
+func (t *Target) register() usm.Register {
+	t.registers++
+	return t.registers
+}
+
+//WriteTo writes the target, beginning with the header and the constants.
 func (t *Target) WriteTo(writer io.Writer) (int64, error) {
-	return t.Target.WriteTo(writer)
+	n, err := Header{Version: Version}.WriteTo(writer)
+	if err != nil {
+		return n, err
+	}
+	m, err := t.writeConstants(writer)
+	n += m
+	if err != nil {
+		return n, err
+	}
+	m, err = t.Target.WriteTo(writer)
+	return n + m, err
 }
 
 //Main is the entrypoint of the program.
 func (t *Target) Main(body usm.Block) {
 	t.WriteByte(Main)
+	t.WriteFunction(body)
+}
+
+//Var creates a new variable set to the provided value.
+//Returns the register for future reference to the variable.
+func (t *Target) Var(value usm.Value) usm.Register {
+	t.WriteByte(Var)
+	t.WriteValue(value)
+	return t.register()
+}
+
+//Set sets the variable in the given register to be the given value.
+func (t *Target) Set(register usm.Register, value usm.Value) {
+	t.WriteByte(Set)
+	t.WriteInt64(int64(register))
+	t.WriteValue(value)
+}
+
+//Discard allows a value to be used as a statement.
+func (t *Target) Discard(value usm.Value) {
+	t.statement(Discard, value)
+}
+
+//If branches to the body Block if the condition is not zero.
+//If the condition is zero, this process follows the chain, treating them as elseif's.
+//The last block is branched to if none of the previous branches were followed.
+func (t *Target) If(condition usm.Bit, body usm.Block, chain []usm.ElseIf, last usm.Block) {
+	t.WriteByte(If)
+	t.WriteLength(len(chain))
+	if last == nil {
+		t.WriteByte(0)
+	} else {
+		t.WriteByte(1)
+	}
+
+	//The conditions are written before the blocks, so that they can be read before the If is assembled.
+	t.WriteValue(condition)
+	for _, elseif := range chain {
+		t.WriteValue(elseif.Bit)
+	}
+
 	t.WriteBlock(body)
+	for _, elseif := range chain {
+		t.WriteBlock(elseif.Block)
+	}
+	if last != nil {
+		t.WriteBlock(last)
+	}
 }
 
 //Loop loops the body while an optional condition is true.
@@ -57,33 +207,139 @@ func (t *Target) Loop(condition usm.Number, body usm.Block) {
 	t.WriteBlock(body)
 }
 
+//Each loops over an array, placing the index into 'i' and the value into 'v'.
+//The registers of 'i' and 'v' are written so that the body can refer to them.
+func (t *Target) Each(array usm.Array, body func(i usm.Number, v usm.Value)) {
+	var index, value = t.register(), t.register()
+
+	t.statement(Each, array)
+	t.WriteInt64(int64(index))
+	t.WriteInt64(int64(value))
+	t.WriteBlock(func() {
+		body(t.Get(index), t.Get(value))
+	})
+}
+
+//Range creates a loop that runs the iterator from 'from' to 'to'
+//under the relationship constraint with a given step.
+//Relationship -2: <, -1:<=, 0: =, 1: >=, 2: >
+//The register of the iterator is written so that the body can refer to it.
+func (t *Target) Range(from usm.Number, relationship int, to usm.Number, step usm.Number,
+	body func(i usm.Number)) {
+
+	var iterator = t.register()
+
+	t.statement(Range, from)
+	t.WriteInt64(int64(relationship))
+	t.WriteValue(to)
+	t.WriteValue(step)
+	t.WriteInt64(int64(iterator))
+	t.WriteBlock(func() {
+		body(t.Get(iterator))
+	})
+}
+
+//Break breaks the inner-most loop.
+func (t *Target) Break() {
+	t.WriteByte(Break)
+}
+
+//Define defines a function, returning the label to the function.
+//arguments is the number of the arguments the function expects.
+//The label is reserved before the body is written, so that the body is able to call itself.
+func (t *Target) Define(arguments int, body usm.Block) usm.Label {
+	t.labels++
+	var label = t.labels
+	t.WriteByte(Define)
+	t.WriteLength(arguments)
+	t.WriteFunction(body)
+	return label
+}
+
+//NextLabel returns the label that the next call to Define returns.
+func (t *Target) NextLabel() usm.Label {
+	return t.labels + 1
+}
+
+//Return returns the result to the caller.
+//Pass nil to return without passing a value.
+func (t *Target) Return(result usm.Value) {
+	t.statement(Return, result)
+}
+
+//JumpTo jumps to the label passing the provided arguments.
+//JumpTo ignores any return values.
+//If the label is 0, then the first argument is treated as a label bind and subsequent arguments are passed.
+func (t *Target) JumpTo(label usm.Label, arguments ...usm.Value) {
+	t.WriteByte(JumpTo)
+	t.WriteInt64(int64(label))
+	t.WriteLength(len(arguments))
+	for _, arg := range arguments {
+		t.WriteValue(arg)
+	}
+}
+
+//Throw throws an Value onto the thread-local Errors stack.
+func (t *Target) Throw(value usm.Value) {
+	t.statement(Throw, value)
+}
+
+//Seek attempts to advance the stream by discarding a specified number of bytes from the stream.
+func (t *Target) Seek(stream usm.Stream, amount usm.Number) {
+	t.statement(Seek, stream, amount)
+}
+
+//Delete frees the memory of the given Value.
+//The type is not written, so the value is deleted with a nil type when the bytecode is read.
+func (t *Target) Delete(_ usm.Type, value usm.Value) {
+	t.statement(Delete, value)
+}
+
+//Change changes the pointer value to the provided Value.
+func (t *Target) Change(pointer usm.Pointer, value usm.Value) {
+	t.statement(Change, pointer, value)
+}
+
+//Mutate mutates the array at the given index to be set to the given value.
+func (t *Target) Mutate(array usm.Array, index usm.Number, value usm.Value) {
+	t.statement(Mutate, array, index, value)
+}
+
+//Insert sets the table value at the given string key to be set to the given value.
+func (t *Target) Insert(table usm.Table, key usm.String, value usm.Value) {
+	t.statement(Insert, table, key, value)
+}
+
+//Remove removes the given key from the table.
+func (t *Target) Remove(table usm.Value, key usm.Value) {
+	t.statement(Remove, table, key)
+}
+
+//Modify mutates a string and sets the index to be set to the given number.
+func (t *Target) Modify(s usm.String, index usm.Number, number usm.Number) {
+	t.statement(Modify, s, index, number)
+}
+
 //String returns the String given by the go.string
 func (t *Target) String(s string) usm.Value {
 	var b bytes.Buffer
 	b.WriteByte(String) //Header
 
-	writeInt64(&b, int64(len(s)))
+	writeLength(&b, len(s))
 	b.Write([]byte(s))
-	return b.Bytes()
-}
-
-//Create creates a new String of the given size.
-func (t *Target) Create(n usm.Number) usm.String {
-	var b bytes.Buffer
-	b.WriteByte(Create) //Header
-	b.Write(n.([]byte))
-	return b.Bytes()
+	return t.constant(b.Bytes())
 }
 
 //Number returns the Number given by the *go.big.Int
-func (t *Target) Number(i *big.Int) usm.Value {
+//The magnitude is written in big-endian order, after its length which is negated for negative numbers.
+func (t *Target) Number(i *big.Int) usm.Number {
 	var b bytes.Buffer
 	b.WriteByte(Number) //Header
 
 	var bytes = i.Bytes()
-	writeInt64(&b, int64(len(bytes)))
-	b.Write([]byte(bytes))
-	return b.Bytes()
+	writeInt64(&b, int64(i.Sign()*len(bytes)))
+	b.Write(bytes)
+	return t.constant(b.Bytes())
 }
 
 //Bit returns the Bit given by the go.bool
@@ -97,65 +353,234 @@ func (t *Target) Bit(bit bool) usm.Value {
 		b.WriteByte(0)
 	}
 
-	return b.Bytes()
+	return NewValue(b.Bytes())
 }
 
-//Define defines a function, returning the label to the function.
-//arguments is the number of the arguments the function expects.
-func (t *Target) Define(arguments int, body usm.Block) usm.Label {
-	t.WriteByte(Define)
-	t.WriteInt64(int64(arguments))
-	t.WriteBlock(body)
-	t.labels++
-	return t.labels
+//Get returns the value inside of the given register.
+func (t *Target) Get(register usm.Register) usm.Value {
+	var b bytes.Buffer
+	b.WriteByte(Get) //Header
+	writeInt64(&b, int64(register))
+	return NewValue(b.Bytes())
 }
 
-//Var creates a new variable set to the provided value.
-//Returns the register for future reference to the variable.
-func (t *Target) Var(value usm.Value) usm.Register {
-	t.WriteByte(Var)
-	t.WriteValue(value)
-	t.registers++
-	return t.registers
+//Bind returns the label as a value that can be passed to a Call, JumpTo or Fork by passing an empty function argument
+func (t *Target) Bind(label usm.Label) usm.Value {
+	var b bytes.Buffer
+	b.WriteByte(Bind) //Header
+	writeInt64(&b, int64(label))
+	return NewValue(b.Bytes())
 }
 
-//JumpTo jumps to the label passing the provided arguments.
-//JumpTo ignores any return values.
+//Catch removes and returns the latest error on the thread-local error stack.
+func (t *Target) Catch() usm.Value {
+	return expression(Catch)
+}
+
+//Errors returns the number of errors on the thread-local error stack.
+func (t *Target) Errors() usm.Number {
+	return expression(Errors)
+}
+
+//Call calls the provided label, passing the provided argument values and returns the result.
 //If the label is 0, then the first argument is treated as a label bind and subsequent arguments are passed.
-func (t *Target) JumpTo(label usm.Label, arguments ...usm.Value) {
-	t.WriteByte(JumpTo)
-	t.WriteInt64(int64(label))
-	t.WriteInt64(int64(len(arguments)))
-	for _, arg := range arguments {
-		t.Write(arg.([]byte))
+func (t *Target) Call(label usm.Label, args ...usm.Value) usm.Value {
+	return call(Call, label, args)
+}
+
+//Fork jumps to the label in an independant parallel runtime, the arguments are passed.
+//A connected stream is returned, this connects to the Stdin and Stdout of the new runtime.
+func (t *Target) Fork(label usm.Label, args ...usm.Value) usm.Stream {
+	return call(Fork, label, args)
+}
+
+//Pointer retuns a pointer to the provided value.
+func (t *Target) Pointer(value usm.Value) usm.Pointer {
+	return expression(Pointer, value)
+}
+
+//Alloc creates a new array of the given size.
+func (t *Target) Alloc(size usm.Number) usm.Array {
+	return expression(Alloc, size)
+}
+
+//Array creates a new array with the given elements.
+func (t *Target) Array(elements ...usm.Value) usm.Array {
+	var b bytes.Buffer
+	b.WriteByte(Array) //Header
+	writeLength(&b, len(elements))
+	for _, element := range elements {
+		writeValue(&b, element)
 	}
+	return NewValue(b.Bytes())
 }
 
-//Send writes the string data into the stream, returns the number of bytes written.
-//This may throw an error.
-func (t *Target) Send(stream usm.Stream, s usm.String) usm.Value {
-	if stream == nil {
-		var b bytes.Buffer
-		b.WriteByte(Send)
-		b.WriteByte(Nil)
-		b.Write(s.([]byte))
-		return b.Bytes()
+//Table creates a new table with the given elements.
+//The elements are written in order of their encoding, so that the output does not depend on map order.
+func (t *Target) Table(elements map[usm.Value]usm.Value) usm.Table {
+	var encoded = make([][]byte, 0, len(elements))
+	for key, value := range elements {
+		var element bytes.Buffer
+		writeValue(&element, key)
+		writeValue(&element, value)
+		encoded = append(encoded, element.Bytes())
 	}
-	panic("not implemented")
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	var b bytes.Buffer
+	b.WriteByte(Table) //Header
+	writeLength(&b, len(encoded))
+	for _, element := range encoded {
+		b.Write(element)
+	}
+	return NewValue(b.Bytes())
 }
 
-//Discard allows a value to be used as a statement.
-func (t *Target) Discard(value usm.Value) {
-	t.WriteByte(Discard)
-	t.Write(value.([]byte))
+//Count returns the number of elements in the array.
+func (t *Target) Count(array usm.Array) usm.Number {
+	return expression(Count, array)
+}
+
+//Index returns the value at the given index in the array.
+func (t *Target) Index(array usm.Array, index usm.Number) usm.Value {
+	return expression(Index, array, index)
+}
+
+//Append adds an element to the end of the array.
+func (t *Target) Append(array usm.Array, value usm.Value) usm.Array {
+	return expression(Append, array, value)
+}
+
+//Amount returns the number of items in the Table.
+func (t *Target) Amount(table usm.Table) usm.Value {
+	return expression(Amount, table)
+}
+
+//Lookup returns the value at the given key in the Table.
+func (t *Target) Lookup(table usm.Table, key usm.String) usm.Value {
+	return expression(Lookup, table, key)
+}
+
+//Create creates a new String of the given size.
+func (t *Target) Create(n usm.Number) usm.String {
+	return expression(Create, n)
+}
+
+//Equals returns 1 is the two Strings are equal. Returns 0 otherwise.
+func (t *Target) Equals(a usm.String, b usm.String) usm.Bit {
+	return expression(Equals, a, b)
+}
+
+//Length returns the length of the String in bytes.
+func (t *Target) Length(s usm.String) usm.Number {
+	return expression(Length, s)
+}
+
+//Symbol returns the byte at the given index in the String.
+func (t *Target) Symbol(s usm.String, index usm.Number) usm.Number {
+	return expression(Symbol, s, index)
+}
+
+//Concat creates a new String that is the concatenation of the given strings.
+func (t *Target) Concat(a usm.String, b usm.String) usm.String {
+	return expression(Concat, a, b)
+}
+
+//Follow returns the value that the pointer is pointing at.
+func (t *Target) Follow(pointer usm.Pointer) usm.Value {
+	return expression(Follow, pointer)
+}
+
+//Open returns a stream from the given platform-dependent URI.
+//This may throw an error.
+func (t *Target) Open(uri usm.String) usm.Stream {
+	return expression(Open, uri)
+}
+
+//Stat performs a platform-dependent stat on the stream and returns the result.
+func (t *Target) Stat(stream usm.Stream) usm.String {
+	return expression(Stat, stream)
 }
 
 //Read reads stream data into the given string, returns the number of bytes read.
 //This may throw an error.
 func (t *Target) Read(stream usm.Stream, s usm.String) usm.Value {
+	return expression(Read, stream, s)
+}
+
+//Send writes the string data into the stream, returns the number of bytes written.
+//This may throw an error.
+func (t *Target) Send(stream usm.Stream, s usm.String) usm.Value {
+	return expression(Send, stream, s)
+}
+
+//Add returns the sum of a and b.
+func (t *Target) Add(a usm.Number, b usm.Number) usm.Number {
+	return expression(Add, a, b)
+}
+
+//Mul returns the product of a and b.
+func (t *Target) Mul(a usm.Number, b usm.Number) usm.Number {
+	return expression(Mul, a, b)
+}
+
+//Sub returns the difference between a and b.
+func (t *Target) Sub(a usm.Number, b usm.Number) usm.Number {
+	return expression(Sub, a, b)
+}
+
+//Div returns the quotient of a and b.
+func (t *Target) Div(a usm.Number, b usm.Number) usm.Number {
+	return expression(Div, a, b)
+}
+
+//Mod returns the modulos of a and b.
+func (t *Target) Mod(a usm.Number, b usm.Number) usm.Number {
+	return expression(Mod, a, b)
+}
+
+//Pow returns a to the power of b.
+func (t *Target) Pow(a usm.Number, b usm.Number) usm.Number {
+	return expression(Pow, a, b)
+}
+
+//Less returns 1 if a is smaller than b, otherwise 0.
+func (t *Target) Less(a usm.Number, b usm.Number) usm.Bit {
+	return expression(Less, a, b)
+}
+
+//More returns 1 if a is larger than b, otherwise 0.
+func (t *Target) More(a usm.Number, b usm.Number) usm.Bit {
+	return expression(More, a, b)
+}
+
+//Same returns 1 if a is equal to b, otherwise 0.
+func (t *Target) Same(a usm.Number, b usm.Number) usm.Bit {
+	return expression(Same, a, b)
+}
+
+//And returns a && b
+func (t *Target) And(a usm.Bit, b usm.Bit) usm.Bit {
+	return expression(And, a, b)
+}
+
+//Or returns a || b
+func (t *Target) Or(a usm.Bit, b usm.Bit) usm.Bit {
+	return expression(Or, a, b)
+}
+
+//Not returns !Bit
+func (t *Target) Not(bit usm.Bit) usm.Bit {
+	return expression(Not, bit)
+}
+
+//Native creates a native-target value from the specified target-dependant bytes.
+func (t *Target) Native(data []byte) usm.Native {
 	var b bytes.Buffer
-	b.WriteByte(Read)
-	b.Write(stream.([]byte))
-	b.Write(s.([]byte))
-	return b.Bytes()
+	b.WriteByte(Native) //Header
+	writeLength(&b, len(data))
+	b.Write(data)
+	return NewValue(b.Bytes())
 }
@@ -95,8 +95,8 @@ func (t *Target) Define(arguments int, body usm.Block) usm.Label {
 	}
 
 	var backup = t.Buffer
-	var old = t.Tabs
-	t.Tabs = 0
+	var old, registers = t.Tabs, t.Registers
+	t.Tabs, t.Registers = 0, 0
 
 	t.Buffer = bytes.Buffer{}
 
@@ -112,7 +112,7 @@ func (t *Target) Define(arguments int, body usm.Block) usm.Label {
 
 	t.Head.Write(t.Buffer.Bytes())
 
-	t.Tabs = old
+	t.Tabs, t.Registers = old, registers
 	t.Buffer = backup
 
 	return t.Labels
@@ -130,6 +130,9 @@ func (t *Target) Var(value usm.Value) usm.Register {
 
 //Get returns the value inside of the given register.
 func (t *Target) Get(r usm.Register) usm.Value {
+	if r < 0 {
+		return fmt.Sprintf(`a%v`, -r-1)
+	}
 	return fmt.Sprintf(`v%v`, r)
 }
 
@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//Array is a runtime usm.Array, arrays are shared by reference and grow when appended to.
+type Array struct {
+	Values []interface{}
+}
+
+//ElementSize is the number of bytes that an array element counts towards Limits.Memory.
+const ElementSize = 16
+
+//Alloc creates a new array of the given size.
+func (t *Target) Alloc(size usm.Number) usm.Array {
+	var n = size.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var size = n.Evaluate(r).(*big.Int)
+		return r.effect(func() interface{} {
+			if size.Sign() < 0 || !size.IsInt64() {
+				r.Raise([]byte("invalid array size"))
+				return &Array{}
+			}
+			r.Allocate(size.Int64() * ElementSize)
+			return &Array{
+				Values: make([]interface{}, size.Int64()),
+			}
+		})
+	})
+}
+
+//Array creates a new array with the given elements.
+func (t *Target) Array(elements ...usm.Value) usm.Array {
+	var converted = make([]Value, len(elements))
+	for i := range elements {
+		converted[i] = elements[i].(Value)
+	}
+	return NewValue(func(r *Runtime) interface{} {
+		var elements = evaluate(r, converted)
+		return r.effect(func() interface{} {
+			r.Allocate(int64(len(elements)) * ElementSize)
+			return &Array{Values: elements}
+		})
+	})
+}
+
+//Count returns the number of elements in the array.
+func (t *Target) Count(array usm.Array) usm.Number {
+	var a = array.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return big.NewInt(int64(len(a.Evaluate(r).(*Array).Values)))
+	})
+}
+
+//Index returns the value at the given index in the array.
+func (t *Target) Index(array usm.Array, index usm.Number) usm.Value {
+	var a = array.(Value)
+	var i = index.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var array, index = a.Evaluate(r).(*Array), i.Evaluate(r).(*big.Int)
+		if !r.Bounds(index, len(array.Values)) {
+			return nil
+		}
+		return array.Values[index.Int64()]
+	})
+}
+
+//Append adds an element to the end of the array.
+//The array is modified in place and returned.
+func (t *Target) Append(array usm.Array, value usm.Value) usm.Array {
+	var a = array.(Value)
+	var v = value.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var array, value = a.Evaluate(r).(*Array), v.Evaluate(r)
+		return r.effect(func() interface{} {
+			r.Allocate(ElementSize)
+			array.Values = append(array.Values, value)
+			return array
+		})
+	})
+}
+
+//Mutate mutates the array at the given index to be set to the given value.
+func (t *Target) Mutate(array usm.Array, index usm.Number, value usm.Value) {
+	var a = array.(Value)
+	var i = index.(Value)
+	var v = value.(Value)
+	t.Write(func(r *Runtime) {
+		var array, index = a.Evaluate(r).(*Array), i.Evaluate(r).(*big.Int)
+		if r.Bounds(index, len(array.Values)) {
+			array.Values[index.Int64()] = v.Evaluate(r)
+		}
+	})
+}
+
+//Each loops over an array, placing the index into 'i' and the value into 'v'.
+//The length of the array is taken once, so values that the body appends are not visited.
+func (t *Target) Each(array usm.Array, body func(i usm.Number, v usm.Value)) {
+	var a = array.(Value)
+
+	//The loop state is kept in registers so that it belongs to the frame.
+	var index, value, values, length = t.register(), t.register(), t.register(), t.register()
+	var block = t.Block(func() {
+		body(t.Get(index), t.Get(value))
+	})
+
+	t.Write(func(r *Runtime) {
+		var i int
+		if r.resuming(block) {
+			i = int(r.Variables[index-1].(*big.Int).Int64())
+			if !r.Loop(block) {
+				return
+			}
+			i++
+		} else {
+			var array = a.Evaluate(r).(*Array)
+			r.Variables[values-1], r.Variables[length-1] = array, big.NewInt(int64(len(array.Values)))
+		}
+
+		var array, n = r.Variables[values-1].(*Array), int(r.Variables[length-1].(*big.Int).Int64())
+		for ; i < n; i++ {
+			r.Variables[index-1], r.Variables[value-1] = big.NewInt(int64(i)), array.Values[i]
+			if !r.Loop(block) {
+				return
+			}
+		}
+	})
+}
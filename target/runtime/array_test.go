@@ -0,0 +1,91 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestCollections(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target, show usm.Label)
+		output  string
+	}{
+		{"alloc", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(show, c.Count(c.Alloc(number(c, 3))))
+		}, "3"},
+		{"invalid alloc", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Alloc(number(c, -1)))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "invalid array size"},
+		{"index", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(show, c.Index(c.Array(number(c, 4), number(c, 5), number(c, 6)), number(c, 1)))
+		}, "5"},
+		{"index out of range", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Index(c.Array(number(c, 4)), number(c, 1)))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "index out of range"},
+		{"mutate out of range", func(c *runtime.Target, show usm.Label) {
+			c.Mutate(c.Array(number(c, 4)), number(c, -1), number(c, 5))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "index out of range"},
+		{"shared array", func(c *runtime.Target, show usm.Label) {
+			var a = c.Var(c.Array(number(c, 1)))
+			var b = c.Var(c.Get(a))
+			c.Mutate(c.Get(b), number(c, 0), number(c, 2))
+			c.JumpTo(show, c.Index(c.Get(a), number(c, 0)))
+		}, "2"},
+		{"append", func(c *runtime.Target, show usm.Label) {
+			var a = c.Var(c.Alloc(number(c, 0)))
+			c.Range(number(c, 0), -2, number(c, 5), number(c, 1), func(i usm.Number) {
+				c.Discard(c.Append(c.Get(a), i))
+			})
+			c.JumpTo(show, c.Count(c.Get(a)))
+			c.JumpTo(show, c.Index(c.Get(a), number(c, 4)))
+		}, "54"},
+		{"table", func(c *runtime.Target, show usm.Label) {
+			var table = c.Var(c.Table(map[usm.Value]usm.Value{c.String("a"): c.String("x")}))
+			c.Insert(c.Get(table), c.String("b"), c.String("y"))
+			c.Discard(c.Send(nil, c.Lookup(c.Get(table), c.String("a"))))
+			c.Discard(c.Send(nil, c.Lookup(c.Get(table), c.String("b"))))
+			c.JumpTo(show, c.Amount(c.Get(table)))
+		}, "xy2"},
+		{"overwrite", func(c *runtime.Target, show usm.Label) {
+			var table = c.Var(c.Table(nil))
+			c.Insert(c.Get(table), c.String("a"), c.String("x"))
+			c.Insert(c.Get(table), c.String("a"), c.String("y"))
+			c.Discard(c.Send(nil, c.Lookup(c.Get(table), c.String("a"))))
+			c.JumpTo(show, c.Amount(c.Get(table)))
+		}, "y1"},
+		{"remove", func(c *runtime.Target, show usm.Label) {
+			var table = c.Var(c.Table(map[usm.Value]usm.Value{c.String("a"): c.String("x")}))
+			c.Remove(c.Get(table), c.String("a"))
+			c.Remove(c.Get(table), c.String("missing"))
+			c.JumpTo(show, c.Amount(c.Get(table)))
+		}, "0"},
+		{"shared table", func(c *runtime.Target, show usm.Label) {
+			var a = c.Var(c.Table(nil))
+			var b = c.Var(c.Get(a))
+			c.Insert(c.Get(b), c.String("k"), c.String("v"))
+			c.Discard(c.Send(nil, c.Lookup(c.Get(a), c.String("k"))))
+		}, "v"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, func(c *runtime.Target) {
+				var show = show(c)
+				c.Main(func() {
+					test.program(c, show)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
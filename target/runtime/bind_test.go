@@ -0,0 +1,78 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestBind(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target, show usm.Label)
+		output  string
+	}{
+		{"dispatch array", func(c *runtime.Target, show usm.Label) {
+			var double = c.Define(1, func() {
+				c.Return(c.Mul(c.Get(usm.Arg(0)), number(c, 2)))
+			})
+			var square = c.Define(1, func() {
+				c.Return(c.Mul(c.Get(usm.Arg(0)), c.Get(usm.Arg(0))))
+			})
+			c.Each(c.Array(c.Bind(double), c.Bind(square)), func(i usm.Number, v usm.Value) {
+				c.JumpTo(show, c.Call(0, v, number(c, 5)))
+			})
+		}, "1025"},
+		{"dispatch table", func(c *runtime.Target, show usm.Label) {
+			var double = c.Define(1, func() {
+				c.Return(c.Mul(c.Get(usm.Arg(0)), number(c, 2)))
+			})
+			var table = c.Var(c.Table(map[usm.Value]usm.Value{c.String("double"): c.Bind(double)}))
+			c.JumpTo(show, c.Call(0, c.Lookup(c.Get(table), c.String("double")), number(c, 4)))
+		}, "8"},
+		{"callback", func(c *runtime.Target, show usm.Label) {
+			var apply = c.Define(2, func() {
+				c.JumpTo(0, c.Get(usm.Arg(0)), c.Get(usm.Arg(1)))
+			})
+			c.JumpTo(apply, c.Bind(show), number(c, 7))
+		}, "7"},
+		{"fork", func(c *runtime.Target, show usm.Label) {
+			var child = c.Define(0, func() {
+				c.Discard(c.Send(nil, c.String("f")))
+			})
+			var stream = c.Var(c.Fork(0, c.Bind(child)))
+			var data = c.Var(c.Create(number(c, 1)))
+			c.Discard(c.Read(c.Get(stream), c.Get(data)))
+			c.Discard(c.Send(nil, c.Get(data)))
+		}, "f"},
+		{"not a function", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Call(0, number(c, 1)))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "not a function"},
+		{"no function", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(0)
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "not a function"},
+		{"wrong number of arguments", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Call(0, c.Bind(show)))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "wrong number of arguments"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, func(c *runtime.Target) {
+				var show = show(c)
+				c.Main(func() {
+					test.program(c, show)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//factorial defines a function that returns the factorial of its argument.
+func factorial(c *runtime.Target) usm.Label {
+	var factorial = c.NextLabel()
+	return c.Define(1, func() {
+		var n = c.Get(usm.Arg(0))
+		c.If(c.Less(n, number(c, 2)), func() {
+			c.Return(number(c, 1))
+		}, nil, nil)
+		c.Return(c.Mul(n, c.Call(factorial, c.Sub(n, number(c, 1)))))
+	})
+}
+
+func TestCalls(t *testing.T) {
+	var tests = []struct {
+		name, output string
+		program      func(c *runtime.Target)
+	}{
+		{"show", "9075", func(c *runtime.Target) {
+			c.Main(func() {
+				c.JumpTo(show(c), number(c, 9075))
+			})
+		}},
+		{"factorial", "3628800", func(c *runtime.Target) {
+			c.Main(func() {
+				var show, factorial = show(c), factorial(c)
+				c.JumpTo(show, c.Call(factorial, number(c, 10)))
+			})
+		}},
+		{"fibonacci", "6765", func(c *runtime.Target) {
+			c.Main(func() {
+				var show, fibonacci = show(c), c.NextLabel()
+				c.Define(1, func() {
+					var n = c.Get(usm.Arg(0))
+					c.If(c.Less(n, number(c, 2)), func() {
+						c.Return(n)
+					}, nil, nil)
+					c.Return(c.Add(
+						c.Call(fibonacci, c.Sub(n, number(c, 1))),
+						c.Call(fibonacci, c.Sub(n, number(c, 2))),
+					))
+				})
+				c.JumpTo(show, c.Call(fibonacci, number(c, 20)))
+			})
+		}},
+		{"mutual recursion", "odd", func(c *runtime.Target) {
+			c.Main(func() {
+				//even is defined before odd, so odd is the label after it.
+				var even = c.NextLabel()
+				var odd = even + 1
+				c.Define(1, func() {
+					var n = c.Get(usm.Arg(0))
+					c.If(c.Same(n, number(c, 0)), func() {
+						c.Return(c.Bit(true))
+					}, nil, nil)
+					c.Return(c.Call(odd, c.Sub(n, number(c, 1))))
+				})
+				c.Define(1, func() {
+					var n = c.Get(usm.Arg(0))
+					c.If(c.Same(n, number(c, 0)), func() {
+						c.Return(c.Bit(false))
+					}, nil, nil)
+					c.Return(c.Call(even, c.Sub(n, number(c, 1))))
+				})
+				c.If(c.Call(even, number(c, 7)), func() {
+					c.Discard(c.Send(nil, c.String("even")))
+				}, nil, func() {
+					c.Discard(c.Send(nil, c.String("odd")))
+				})
+			})
+		}},
+		{"argument order", "5", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var difference = c.Define(2, func() {
+					c.Return(c.Sub(c.Get(usm.Arg(0)), c.Get(usm.Arg(1))))
+				})
+				c.JumpTo(show, c.Call(difference, number(c, 9), number(c, 4)))
+			})
+		}},
+		{"arguments are copies", "13", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var increment = c.Define(1, func() {
+					c.Set(usm.Arg(0), c.Add(c.Get(usm.Arg(0)), number(c, 1)))
+					c.Return(c.Get(usm.Arg(0)))
+				})
+				var n = c.Var(number(c, 1))
+				c.JumpTo(increment, c.Get(n))
+				c.JumpTo(show, c.Get(n))
+				c.JumpTo(show, c.Call(increment, number(c, 2)))
+			})
+		}},
+		{"bound function", "720", func(c *runtime.Target) {
+			c.Main(func() {
+				var show, factorial = show(c), factorial(c)
+				var apply = c.Define(2, func() {
+					c.Return(c.Call(0, c.Get(usm.Arg(0)), c.Get(usm.Arg(1))))
+				})
+				c.JumpTo(show, c.Call(apply, c.Bind(factorial), number(c, 6)))
+			})
+		}},
+		{"return without a value", "done", func(c *runtime.Target) {
+			c.Main(func() {
+				var nothing = c.Define(0, func() {
+					c.Return(nil)
+					c.Discard(c.Send(nil, c.String("unreachable")))
+				})
+				c.JumpTo(nothing)
+				c.Discard(c.Send(nil, c.String("done")))
+			})
+		}},
+		{"wrong number of arguments", "wrong number of arguments", func(c *runtime.Target) {
+			c.Main(func() {
+				var pair = c.Define(2, func() {
+					c.Discard(c.Send(nil, c.String("unreachable")))
+				})
+				c.JumpTo(pair, number(c, 1))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}},
+		{"wrong number of arguments to a bound function", "wrong number of arguments", func(c *runtime.Target) {
+			c.Main(func() {
+				var single = c.Define(1, func() {
+					c.Discard(c.Send(nil, c.String("unreachable")))
+				})
+				c.Discard(c.Call(0, c.Bind(single)))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, runtime.Limits{}, test.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+//Message is a Debug Adapter Protocol message.
+type Message struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"`
+
+	//Request fields.
+	Command   string          `json:"command,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	//Response fields.
+	RequestSeq int    `json:"request_seq,omitempty"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+
+	//Event fields.
+	Event string `json:"event,omitempty"`
+
+	Body interface{} `json:"body,omitempty"`
+}
+
+//MaxMessageSize is the largest message that a Conn reads, in bytes.
+const MaxMessageSize = 16 << 20
+
+//Conn reads and writes base protocol framed messages.
+type Conn struct {
+	reader *textproto.Reader
+
+	mutex  sync.Mutex
+	writer io.Writer
+	seq    int
+}
+
+//NewConn returns a Conn that reads and writes messages over the given stream.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{
+		reader: textproto.NewReader(bufio.NewReader(rw)),
+		writer: rw,
+	}
+}
+
+//Read reads the next message.
+func (c *Conn) Read() (Message, error) {
+	var message Message
+
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		return message, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return message, fmt.Errorf("dap: invalid Content-Length: %w", err)
+	}
+	if length < 0 || length > MaxMessageSize {
+		return message, fmt.Errorf("dap: invalid Content-Length: %v", length)
+	}
+
+	var content = make([]byte, length)
+	if _, err := io.ReadFull(c.reader.R, content); err != nil {
+		return message, err
+	}
+	if err := json.Unmarshal(content, &message); err != nil {
+		return message, fmt.Errorf("dap: invalid message: %w", err)
+	}
+	return message, nil
+}
+
+//Write writes the message, setting its sequence number.
+func (c *Conn) Write(message Message) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	message.Seq = c.seq
+
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(content)
+	return err
+}
+
+//Respond writes a successful response to the request.
+func (c *Conn) Respond(request Message, body interface{}) error {
+	return c.Write(Message{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Command:    request.Command,
+		Success:    true,
+		Body:       body,
+	})
+}
+
+//Fail writes an unsuccessful response to the request.
+func (c *Conn) Fail(request Message, err error) error {
+	return c.Write(Message{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Command:    request.Command,
+		Message:    err.Error(),
+	})
+}
+
+//Emit writes an event.
+func (c *Conn) Emit(event string, body interface{}) error {
+	return c.Write(Message{
+		Type:  "event",
+		Event: event,
+		Body:  body,
+	})
+}
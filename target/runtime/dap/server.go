@@ -0,0 +1,529 @@
+//Package dap provides a Debug Adapter Protocol server for the usm runtime interpreter.
+package dap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/bytecode"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//thread is the id of the only thread, forked runtimes are not debugged.
+const thread = 1
+
+//Server serves a single debug session over a Conn.
+//Programs are launched from usm bytecode files, breakpoints are set with function breakpoints named
+//"label" or "label:statement" and each stack frame's line is the number of its statement within its function, see runtime.Location.
+type Server struct {
+	conn *Conn
+
+	target   *runtime.Target
+	debugger *runtime.Debugger
+	entry    bool
+
+	//cancel cancels the context that the program runs with.
+	cancel context.CancelFunc
+
+	//linesStartAt1 is set by the client in the initialize request.
+	linesStartAt1 bool
+
+	//mutex guards the fields below, which are only valid while the runtime is stopped.
+	mutex   sync.Mutex
+	stopped bool
+	frames  []runtime.Frame
+	handles []interface{}
+}
+
+//NewServer returns a new server for the session on the stream.
+func NewServer(rw io.ReadWriter) *Server {
+	return &Server{
+		conn:          NewConn(rw),
+		linesStartAt1: true,
+	}
+}
+
+//Serve serves a debug session over the stream until it disconnects.
+func Serve(rw io.ReadWriter) error {
+	return NewServer(rw).Serve()
+}
+
+//ListenAndServe listens on the TCP address and serves one debug session per connection.
+func ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			Serve(conn)
+		}()
+	}
+}
+
+//Serve handles requests until the client disconnects, the program is then stopped.
+func (s *Server) Serve() error {
+	defer s.stop(false)
+	for {
+		request, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if request.Type != "request" {
+			continue
+		}
+
+		done, err := s.handle(request)
+		if err != nil {
+			s.conn.Fail(request, err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+//handle handles the request, returning true if the session is over.
+func (s *Server) handle(request Message) (bool, error) {
+	switch request.Command {
+	case "initialize":
+		var args struct {
+			LinesStartAt1 *bool `json:"linesStartAt1"`
+		}
+		if err := decode(request, &args); err != nil {
+			return false, err
+		}
+		if args.LinesStartAt1 != nil {
+			s.linesStartAt1 = *args.LinesStartAt1
+		}
+		s.conn.Respond(request, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsTerminateRequest":         true,
+		})
+		return false, s.conn.Emit("initialized", nil)
+
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		if err := decode(request, &args); err != nil {
+			return false, err
+		}
+		if err := s.launch(args.Program); err != nil {
+			return false, err
+		}
+		s.entry = args.StopOnEntry
+		return false, s.conn.Respond(request, nil)
+
+	case "setFunctionBreakpoints":
+		var args struct {
+			Breakpoints []struct {
+				Name string `json:"name"`
+			} `json:"breakpoints"`
+		}
+		if err := decode(request, &args); err != nil {
+			return false, err
+		}
+		if s.debugger == nil {
+			return false, errors.New("not launched")
+		}
+		for _, location := range s.debugger.Breakpoints() {
+			s.debugger.Clear(location.Label, location.Statement)
+		}
+		var breakpoints = make([]map[string]interface{}, len(args.Breakpoints))
+		for i, breakpoint := range args.Breakpoints {
+			location, err := parseLocation(breakpoint.Name)
+			if err != nil {
+				breakpoints[i] = map[string]interface{}{"verified": false, "message": err.Error()}
+				continue
+			}
+			s.debugger.Break(location.Label, location.Statement)
+			breakpoints[i] = map[string]interface{}{"verified": true}
+		}
+		return false, s.conn.Respond(request, map[string]interface{}{"breakpoints": breakpoints})
+
+	case "setBreakpoints", "setExceptionBreakpoints":
+		//usm bytecode has no source lines, breakpoints are set with setFunctionBreakpoints.
+		return false, s.conn.Respond(request, map[string]interface{}{"breakpoints": []interface{}{}})
+
+	case "configurationDone":
+		if s.debugger == nil {
+			return false, errors.New("not launched")
+		}
+		s.conn.Respond(request, nil)
+		var ctx context.Context
+		ctx, s.cancel = context.WithCancel(context.Background())
+		go func() {
+			s.report(s.debugger.Start(ctx, s.entry))
+		}()
+		return false, nil
+
+	case "threads":
+		return false, s.conn.Respond(request, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": thread, "name": "main"}},
+		})
+
+	case "stackTrace":
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if !s.stopped {
+			return false, runtime.ErrNotStopped
+		}
+		var frames = make([]map[string]interface{}, len(s.frames))
+		for i, frame := range s.frames {
+			frames[i] = map[string]interface{}{
+				"id":     i + 1,
+				"name":   name(frame),
+				"line":   s.line(frame.Statement),
+				"column": 0,
+			}
+		}
+		return false, s.conn.Respond(request, map[string]interface{}{
+			"stackFrames": frames,
+			"totalFrames": len(frames),
+		})
+
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := decode(request, &args); err != nil {
+			return false, err
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if !s.stopped || args.FrameID < 1 || args.FrameID > len(s.frames) {
+			return false, fmt.Errorf("invalid frame %v", args.FrameID)
+		}
+		var frame = s.frames[args.FrameID-1]
+		errs, err := s.debugger.Errors()
+		if err != nil {
+			return false, err
+		}
+		return false, s.conn.Respond(request, map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				s.scope("Arguments", values(frame.Args, "a")),
+				s.scope("Registers", values(frame.Variables, "r")),
+				s.scope("Errors", values(errs, "e")),
+			},
+		})
+
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := decode(request, &args); err != nil {
+			return false, err
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if !s.stopped || args.VariablesReference < 1 || args.VariablesReference > len(s.handles) {
+			return false, fmt.Errorf("invalid reference %v", args.VariablesReference)
+		}
+		var variables = []map[string]interface{}{}
+		for _, v := range children(s.handles[args.VariablesReference-1]) {
+			variables = append(variables, map[string]interface{}{
+				"name":               v.name,
+				"value":              format(v.value),
+				"type":               runtime.Kind(v.value),
+				"variablesReference": s.reference(v.value),
+			})
+		}
+		return false, s.conn.Respond(request, map[string]interface{}{"variables": variables})
+
+	case "continue":
+		return false, s.resume(request, s.debugger.Continue)
+	case "next":
+		return false, s.resume(request, s.debugger.StepOver)
+	case "stepIn":
+		return false, s.resume(request, s.debugger.Step)
+	case "stepOut":
+		return false, s.resume(request, s.debugger.StepOut)
+
+	case "pause":
+		if s.debugger == nil {
+			return false, errors.New("not launched")
+		}
+		s.debugger.Pause()
+		return false, s.conn.Respond(request, nil)
+
+	case "terminate", "disconnect":
+		s.conn.Respond(request, nil)
+		s.stop(request.Command == "terminate")
+		return request.Command == "disconnect", nil
+
+	default:
+		return false, fmt.Errorf("unsupported command %q", request.Command)
+	}
+}
+
+//launch loads the bytecode program at the path.
+func (s *Server) launch(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var target = new(runtime.Target)
+	if err := bytecode.NewReader(file).Target(target); err != nil {
+		return err
+	}
+	if target.Entrypoint == nil {
+		return fmt.Errorf("%v has no main", path)
+	}
+
+	target.Host = runtime.Host{
+		Stdin:  strings.NewReader(""),
+		Stdout: output{s.conn, "stdout"},
+		Stderr: output{s.conn, "stderr"},
+
+		//The program is debugged on the machine that it was launched from, it may read the files there.
+		Open: runtime.OpenFile,
+	}
+
+	s.target = target
+	s.debugger = runtime.NewDebugger(&target.Runtime)
+	return nil
+}
+
+//resume responds to the request and resumes the runtime with the given debugger method.
+func (s *Server) resume(request Message, method func() (runtime.Stop, error)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.stopped {
+		return runtime.ErrNotStopped
+	}
+	s.stopped = false
+	s.frames, s.handles = nil, nil
+
+	s.conn.Respond(request, map[string]interface{}{"allThreadsContinued": true})
+	go func() {
+		stop, err := method()
+		if err != nil {
+			return
+		}
+		s.report(stop)
+	}()
+	return nil
+}
+
+//stop stops the program, whether it is stopped by the debugger or running.
+//A running program is cancelled, the goroutine that waits for it then reports that it exited.
+//If report is true, then the exit of a stopped program is reported to the client.
+func (s *Server) stop(report bool) {
+	s.mutex.Lock()
+	var stopped = s.stopped
+	s.stopped = false
+	s.mutex.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if stopped {
+		stop, err := s.debugger.Terminate()
+		if err == nil && report {
+			s.report(stop)
+		}
+	}
+}
+
+//report reports the stop to the client.
+func (s *Server) report(stop runtime.Stop) {
+	if stop.Reason == runtime.Exited {
+		var code = 0
+		if stop.Err != nil {
+			code = 1
+			if stop.Err != runtime.ErrTerminated && !errors.Is(stop.Err, context.Canceled) {
+				s.conn.Emit("output", map[string]interface{}{
+					"category": "stderr",
+					"output":   stop.Err.Error() + "\n",
+				})
+			}
+		}
+		s.conn.Emit("exited", map[string]interface{}{"exitCode": code})
+		s.conn.Emit("terminated", nil)
+		return
+	}
+
+	frames, _ := s.debugger.Frames()
+
+	s.mutex.Lock()
+	s.stopped, s.frames, s.handles = true, frames, nil
+	s.mutex.Unlock()
+
+	s.conn.Emit("stopped", map[string]interface{}{
+		"reason":            stop.Reason.String(),
+		"threadId":          thread,
+		"allThreadsStopped": true,
+	})
+}
+
+func (s *Server) line(statement int) int {
+	if s.linesStartAt1 {
+		return statement + 1
+	}
+	return statement
+}
+
+//scope returns a DAP scope for the variables.
+func (s *Server) scope(name string, variables []variable) map[string]interface{} {
+	s.handles = append(s.handles, variables)
+	return map[string]interface{}{
+		"name":               name,
+		"variablesReference": len(s.handles),
+		"namedVariables":     len(variables),
+		"expensive":          false,
+	}
+}
+
+//reference returns a variables reference for the value, or 0 if it has no children.
+func (s *Server) reference(value interface{}) int {
+	switch value.(type) {
+	case *runtime.Array, *runtime.Table, *runtime.Pointer:
+		s.handles = append(s.handles, value)
+		return len(s.handles)
+	default:
+		return 0
+	}
+}
+
+//output sends writes to the client as output events.
+type output struct {
+	conn     *Conn
+	category string
+}
+
+func (o output) Write(b []byte) (int, error) {
+	if err := o.conn.Emit("output", map[string]interface{}{
+		"category": o.category,
+		"output":   string(b),
+	}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+type variable struct {
+	name  string
+	value interface{}
+}
+
+//values names the values with the given prefix and their index.
+func values(list []interface{}, prefix string) []variable {
+	var variables = make([]variable, len(list))
+	for i, value := range list {
+		variables[i] = variable{prefix + strconv.Itoa(i), value}
+	}
+	return variables
+}
+
+//children returns the children of a handle.
+func children(handle interface{}) []variable {
+	switch v := handle.(type) {
+	case []variable:
+		return v
+	case *runtime.Array:
+		var variables = make([]variable, len(v.Values))
+		for i, value := range v.Values {
+			variables[i] = variable{"[" + strconv.Itoa(i) + "]", value}
+		}
+		return variables
+	case *runtime.Table:
+		var keys = make([]string, 0, len(v.Values))
+		for key := range v.Values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var variables = make([]variable, len(keys))
+		for i, key := range keys {
+			variables[i] = variable{strconv.Quote(key), v.Values[key]}
+		}
+		return variables
+	case *runtime.Pointer:
+		return []variable{{"*", v.Value}}
+	default:
+		return nil
+	}
+}
+
+//format formats a runtime value for display.
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return strconv.Quote(string(v))
+	case bool:
+		return strconv.FormatBool(v)
+	case *runtime.Array:
+		return fmt.Sprintf("array(%d)", len(v.Values))
+	case *runtime.Table:
+		return fmt.Sprintf("table(%d)", len(v.Values))
+	case runtime.Function:
+		return fmt.Sprintf("function %d", v.Label)
+	default:
+		return runtime.Kind(value)
+	}
+}
+
+//name returns the display name of a frame.
+func name(frame runtime.Frame) string {
+	var name = "main"
+	if frame.Label != 0 {
+		name = fmt.Sprintf("label %d", frame.Label)
+	}
+	if !frame.Function {
+		name += " (block)"
+	}
+	return name
+}
+
+//parseLocation parses a function breakpoint name of the form "label" or "label:statement".
+func parseLocation(name string) (runtime.Location, error) {
+	var parts = strings.SplitN(strings.TrimSpace(name), ":", 2)
+
+	label, err := strconv.Atoi(parts[0])
+	if err != nil || label < 0 {
+		return runtime.Location{}, fmt.Errorf("invalid label %q", parts[0])
+	}
+
+	var statement int
+	if len(parts) == 2 {
+		if statement, err = strconv.Atoi(parts[1]); err != nil || statement < 0 {
+			return runtime.Location{}, fmt.Errorf("invalid statement %q", parts[1])
+		}
+	}
+	return runtime.Location{Label: usm.Label(label), Statement: statement}, nil
+}
+
+func decode(request Message, args interface{}) error {
+	if len(request.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(request.Arguments, args)
+}
@@ -0,0 +1,280 @@
+package dap_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qlova/usm/target/bytecode"
+	"github.com/qlova/usm/target/runtime/dap"
+)
+
+func TestContentLength(t *testing.T) {
+	for _, length := range []string{"-1", "1000000000000", "x"} {
+		var conn = dap.NewConn(readWriter{strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")})
+		if _, err := conn.Read(); err == nil {
+			t.Fatalf("Content-Length %v: expected an error", length)
+		}
+	}
+}
+
+//readWriter is a stream that discards what is written to it.
+type readWriter struct {
+	*strings.Reader
+}
+
+func (readWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+//session is a client of a Server that debugs a program.
+type session struct {
+	t *testing.T
+
+	//path is the bytecode file of the program.
+	path string
+
+	conn   *dap.Conn
+	served chan error
+
+	//messages is buffered so that the server is never blocked on a response while requests are written.
+	messages chan dap.Message
+}
+
+//newSession writes the program to a temporary file and starts a server for it, close must be called once the test is over.
+func newSession(t *testing.T, program *bytecode.Target) *session {
+	dir, err := ioutil.TempDir("", "usm-dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var path = filepath.Join(dir, "program.usm")
+	file, err := os.Create(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	program.WriteTo(file)
+	file.Close()
+
+	var client, server = net.Pipe()
+	var s = &session{
+		t:        t,
+		path:     path,
+		conn:     dap.NewConn(client),
+		served:   make(chan error, 1),
+		messages: make(chan dap.Message, 64),
+	}
+	go func() {
+		s.served <- dap.Serve(server)
+	}()
+	go func() {
+		for {
+			message, err := s.conn.Read()
+			if err != nil {
+				close(s.messages)
+				return
+			}
+			s.messages <- message
+		}
+	}()
+	return s
+}
+
+func (s *session) close() {
+	s.conn.Write(dap.Message{Type: "request", Command: "disconnect"})
+	os.RemoveAll(filepath.Dir(s.path))
+}
+
+//request sends a request with the arguments.
+func (s *session) request(command string, args interface{}) {
+	s.t.Helper()
+	arguments, _ := json.Marshal(args)
+	if err := s.conn.Write(dap.Message{Type: "request", Command: command, Arguments: arguments}); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+//await returns the next response to the command, or the next event, decoding its body into body if it is not nil.
+//Failed responses fail the test.
+func (s *session) await(response, event string, body interface{}) dap.Message {
+	s.t.Helper()
+	var timeout = time.After(5 * time.Second)
+	for {
+		select {
+		case message, ok := <-s.messages:
+			if !ok {
+				s.t.Fatal("the connection closed")
+			}
+			if message.Type == "response" && !message.Success {
+				s.t.Fatalf("%v failed: %v", message.Command, message.Message)
+			}
+			if (message.Type == "response" && message.Command == response) || (message.Type == "event" && message.Event == event) {
+				if body != nil {
+					encoded, _ := json.Marshal(message.Body)
+					if err := json.Unmarshal(encoded, body); err != nil {
+						s.t.Fatal(err)
+					}
+				}
+				return message
+			}
+		case <-timeout:
+			s.t.Fatalf("timed out waiting for %v%v", response, event)
+		}
+	}
+}
+
+//launch launches the program and starts it.
+func (s *session) launch(stopOnEntry bool) {
+	s.t.Helper()
+	s.request("initialize", map[string]interface{}{})
+	s.request("launch", map[string]interface{}{"program": s.path, "stopOnEntry": stopOnEntry})
+	s.request("configurationDone", nil)
+	s.await("configurationDone", "", nil)
+}
+
+type frame struct {
+	Name string `json:"name"`
+	Line int    `json:"line"`
+}
+
+//stopped waits until the program stops and returns its stack, the innermost frame first.
+func (s *session) stopped() []frame {
+	s.t.Helper()
+	s.await("", "stopped", nil)
+	s.request("stackTrace", map[string]interface{}{"threadId": 1})
+	var trace struct {
+		StackFrames []frame `json:"stackFrames"`
+	}
+	s.await("stackTrace", "", &trace)
+	return trace.StackFrames
+}
+
+//variables returns the variables of the reference, by name.
+func (s *session) variables(reference int) map[string]variable {
+	s.t.Helper()
+	s.request("variables", map[string]interface{}{"variablesReference": reference})
+	var body struct {
+		Variables []variable `json:"variables"`
+	}
+	s.await("variables", "", &body)
+	var variables = make(map[string]variable)
+	for _, v := range body.Variables {
+		variables[v.Name] = v
+	}
+	return variables
+}
+
+type variable struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Reference int    `json:"variablesReference"`
+}
+
+func TestDisconnectRunning(t *testing.T) {
+	var program bytecode.Target
+	program.Main(func() {
+		program.Loop(nil, func() {})
+	})
+	var s = newSession(t, &program)
+	defer os.RemoveAll(filepath.Dir(s.path))
+
+	s.launch(false)
+	s.request("disconnect", nil)
+	s.await("", "exited", nil)
+	if err := <-s.served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+//stepping is a program with a function, so that it can be stepped into and out of.
+func stepping() *bytecode.Target {
+	var program bytecode.Target
+	var function = program.Define(0, func() {
+		program.Discard(program.Send(nil, program.String("a")))
+		program.Discard(program.Send(nil, program.String("b")))
+	})
+	program.Main(func() {
+		program.Var(program.Array(program.Number(big.NewInt(1)), program.String("a")))
+		program.JumpTo(function)
+		program.Discard(program.Send(nil, program.String("c")))
+	})
+	return &program
+}
+
+func TestStepping(t *testing.T) {
+	var s = newSession(t, stepping())
+	defer s.close()
+	s.launch(true)
+
+	var steps = []struct {
+		command string
+		stack   []frame
+	}{
+		{"", []frame{{"main", 1}}},
+		{"next", []frame{{"main", 2}}},
+		{"stepIn", []frame{{"label 1", 1}, {"main", 2}}},
+		{"next", []frame{{"label 1", 2}, {"main", 2}}},
+		{"stepOut", []frame{{"main", 3}}},
+	}
+	for _, step := range steps {
+		if step.command != "" {
+			s.request(step.command, map[string]interface{}{"threadId": 1})
+		}
+		var stack = s.stopped()
+		if len(stack) != len(step.stack) {
+			t.Fatalf("%v: expected %v, got %v", step.command, step.stack, stack)
+		}
+		for i := range stack {
+			if stack[i] != step.stack[i] {
+				t.Fatalf("%v: expected %v, got %v", step.command, step.stack, stack)
+			}
+		}
+	}
+
+	s.request("continue", map[string]interface{}{"threadId": 1})
+	s.await("", "exited", nil)
+}
+
+func TestVariables(t *testing.T) {
+	var s = newSession(t, stepping())
+	defer s.close()
+	s.launch(true)
+
+	//The array is in a register once the first statement has run.
+	s.stopped()
+	s.request("next", map[string]interface{}{"threadId": 1})
+	s.stopped()
+
+	s.request("scopes", map[string]interface{}{"frameId": 1})
+	var body struct {
+		Scopes []struct {
+			Name      string `json:"name"`
+			Reference int    `json:"variablesReference"`
+		} `json:"scopes"`
+	}
+	s.await("scopes", "", &body)
+	var registers int
+	for _, scope := range body.Scopes {
+		if scope.Name == "Registers" {
+			registers = scope.Reference
+		}
+	}
+	if registers == 0 {
+		t.Fatalf("expected a Registers scope, got %+v", body.Scopes)
+	}
+
+	var array, ok = s.variables(registers)["r0"]
+	if !ok || array.Value != "array(2)" || array.Reference == 0 {
+		t.Fatalf("expected the array in r0, got %+v", array)
+	}
+	var elements = s.variables(array.Reference)
+	if elements["[0]"].Value != "1" || elements["[1]"].Value != `"a"` {
+		t.Fatalf("got %+v", elements)
+	}
+}
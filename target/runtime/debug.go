@@ -0,0 +1,273 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/qlova/usm"
+)
+
+//Location identifies a statement, by the label of its function (0 for Main)
+//and its number within that function. The statements of a function are numbered from 0 in the order they are written,
+//including the statements inside of its loops and conditions, so every statement of a function has its own number.
+type Location struct {
+	Label     usm.Label
+	Statement int
+}
+
+//Reason is the reason that a Debugger stopped.
+type Reason int
+
+//These are the reasons that a Debugger can stop.
+const (
+	Entry Reason = iota
+	Breakpoint
+	Step
+	Paused
+	Exited
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Entry:
+		return "entry"
+	case Breakpoint:
+		return "breakpoint"
+	case Step:
+		return "step"
+	case Paused:
+		return "pause"
+	case Exited:
+		return "exited"
+	default:
+		return "unknown"
+	}
+}
+
+//Stop describes why and where a Debugger stopped.
+type Stop struct {
+	Reason
+	Location
+
+	//Err is the error returned by the runtime, if the Reason is Exited.
+	Err error
+}
+
+//Frame is a snapshot of a Scope for inspection.
+type Frame struct {
+	Function bool
+	Location
+
+	Args      []interface{}
+	Variables []interface{}
+}
+
+//mode is how a Debugger resumes a runtime.
+type mode int
+
+const (
+	continuing mode = iota
+	stepping
+	steppingOver
+	steppingOut
+	terminating
+)
+
+//ErrTerminated is returned by the runtime when it is terminated by a Debugger.
+var ErrTerminated = errors.New("runtime.Debugger: terminated")
+
+//ErrNotStopped is returned when a Debugger is asked to resume or inspect a runtime that is not stopped.
+var ErrNotStopped = errors.New("runtime.Debugger: not stopped")
+
+//Debugger controls the execution of a Runtime, the Runtime is paused before statements
+//at a breakpoint and after each step. A Runtime can only be inspected while it is stopped.
+type Debugger struct {
+	runtime *Runtime
+
+	mutex       sync.Mutex
+	breakpoints map[Location]bool
+	pausing     bool
+
+	//mode and depth are only accessed by the runtime while it is running.
+	mode  mode
+	depth int
+
+	stopped bool
+	stops   chan Stop
+	resume  chan mode
+}
+
+//NewDebugger returns a new Debugger attached to the runtime.
+func NewDebugger(r *Runtime) *Debugger {
+	var d = &Debugger{
+		runtime:     r,
+		breakpoints: make(map[Location]bool),
+		stops:       make(chan Stop),
+		resume:      make(chan mode),
+	}
+	r.Debugger = d
+	return d
+}
+
+//Break sets a breakpoint at the statement within the function at the given label.
+func (d *Debugger) Break(label usm.Label, statement int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.breakpoints[Location{label, statement}] = true
+}
+
+//Clear removes the breakpoint at the statement within the function at the given label.
+func (d *Debugger) Clear(label usm.Label, statement int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.breakpoints, Location{label, statement})
+}
+
+//Breakpoints returns the locations of all breakpoints.
+func (d *Debugger) Breakpoints() []Location {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var locations = make([]Location, 0, len(d.breakpoints))
+	for location := range d.breakpoints {
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+//Start runs the runtime on a new goroutine and waits for it to stop.
+//If entry is true, then the runtime stops before its first statement.
+func (d *Debugger) Start(ctx context.Context, entry bool) Stop {
+	if entry {
+		d.mode = stepping
+	} else {
+		d.mode = continuing
+	}
+
+	go func() {
+		var err = d.runtime.RunContext(ctx)
+		d.stops <- Stop{Reason: Exited, Err: err}
+	}()
+
+	return d.wait()
+}
+
+//Pause asks the running runtime to stop before its next statement.
+//Use Wait to wait for it to stop.
+func (d *Debugger) Pause() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.pausing = true
+}
+
+//Wait waits for a running runtime to stop.
+func (d *Debugger) Wait() Stop {
+	return d.wait()
+}
+
+func (d *Debugger) wait() Stop {
+	var stop = <-d.stops
+	d.stopped = stop.Reason != Exited
+	return stop
+}
+
+func (d *Debugger) proceed(m mode) (Stop, error) {
+	if !d.stopped {
+		return Stop{}, ErrNotStopped
+	}
+	d.stopped = false
+	d.resume <- m
+	return d.wait(), nil
+}
+
+//Continue resumes the runtime until it reaches a breakpoint or exits.
+func (d *Debugger) Continue() (Stop, error) {
+	return d.proceed(continuing)
+}
+
+//Step resumes the runtime until the next statement.
+func (d *Debugger) Step() (Stop, error) {
+	return d.proceed(stepping)
+}
+
+//StepOver resumes the runtime until the next statement that is not inside a function called by the current statement.
+func (d *Debugger) StepOver() (Stop, error) {
+	return d.proceed(steppingOver)
+}
+
+//StepOut resumes the runtime until the next statement after the current function returns.
+func (d *Debugger) StepOut() (Stop, error) {
+	return d.proceed(steppingOut)
+}
+
+//Terminate halts the stopped runtime, it exits with ErrTerminated.
+func (d *Debugger) Terminate() (Stop, error) {
+	return d.proceed(terminating)
+}
+
+//Frames returns the scopes of the stopped runtime, the current scope first.
+func (d *Debugger) Frames() ([]Frame, error) {
+	if !d.stopped {
+		return nil, ErrNotStopped
+	}
+
+	var r = d.runtime
+	var frames = make([]Frame, 0, len(r.Scopes)+1)
+
+	var add = func(scope Scope) {
+		frames = append(frames, Frame{
+			Function:  scope.Function,
+			Location:  Location{scope.Label, scope.Statement},
+			Args:      scope.Args,
+			Variables: scope.Variables,
+		})
+	}
+
+	add(r.Scope)
+	for i := len(r.Scopes) - 1; i > 0; i-- {
+		add(r.Scopes[i])
+	}
+	return frames, nil
+}
+
+//Errors returns the error stack of the stopped runtime, the latest error last.
+func (d *Debugger) Errors() ([]interface{}, error) {
+	if !d.stopped {
+		return nil, ErrNotStopped
+	}
+	return d.runtime.Thrown, nil
+}
+
+//statement is called by the runtime before each statement.
+func (d *Debugger) statement(r *Runtime) {
+	var location = Location{r.Label, r.Statement}
+
+	d.mutex.Lock()
+	var reason = Reason(-1)
+	switch {
+	case d.pausing:
+		reason = Paused
+		d.pausing = false
+	case d.breakpoints[location]:
+		reason = Breakpoint
+	case d.mode == stepping,
+		d.mode == steppingOver && r.Depth <= d.depth,
+		d.mode == steppingOut && r.Depth < d.depth:
+		reason = Step
+		if r.Executed == 1 {
+			reason = Entry
+		}
+	}
+	d.mutex.Unlock()
+
+	if reason < 0 {
+		return
+	}
+
+	d.stops <- Stop{Reason: reason, Location: location}
+	d.mode = <-d.resume
+	d.depth = r.Depth
+	if d.mode == terminating {
+		r.Halt(ErrTerminated)
+	}
+}
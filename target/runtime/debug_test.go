@@ -0,0 +1,138 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//nested is a program with statements inside of a loop:
+//the Range is statement 0, the statements in its body are 1 and 2 and the final Discard is 3.
+func nested(c *runtime.Target) {
+	c.Main(func() {
+		c.Range(number(c, 0), -2, number(c, 3), number(c, 1), func(i usm.Number) {
+			c.Discard(i)
+			c.Discard(i)
+		})
+		c.Discard(number(c, 0))
+	})
+}
+
+func TestBreakpointLocations(t *testing.T) {
+	var c runtime.Target
+	nested(&c)
+	var debugger = runtime.NewDebugger(&c.Runtime)
+	debugger.Break(0, 2)
+
+	var stop = debugger.Start(context.Background(), false)
+	for i := 0; i < 3; i++ {
+		if stop.Reason != runtime.Breakpoint || stop.Location != (runtime.Location{Label: 0, Statement: 2}) {
+			t.Fatalf("stop %v: expected the breakpoint at statement 2, got %v at %v", i, stop.Reason, stop.Location)
+		}
+		frames, err := debugger.Frames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 2 || frames[0].Statement != 2 || frames[1].Statement != 0 {
+			t.Fatalf("stop %v: unexpected frames %+v", i, frames)
+		}
+		if stop, err = debugger.Continue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stop.Reason != runtime.Exited || stop.Err != nil {
+		t.Fatalf("expected the program to exit, got %v %v", stop.Reason, stop.Err)
+	}
+}
+
+//calling is a program that calls a function:
+//the function at label 1 has statements 0 and 1, the statements of main are 0, the JumpTo at 1 and 2.
+func calling(c *runtime.Target) {
+	var function = c.Define(0, func() {
+		c.Discard(number(c, 0))
+		c.Discard(number(c, 1))
+	})
+	c.Main(func() {
+		c.Discard(number(c, 0))
+		c.JumpTo(function)
+		c.Discard(number(c, 2))
+	})
+}
+
+func TestStepping(t *testing.T) {
+	type step struct {
+		resume   func(d *runtime.Debugger) (runtime.Stop, error)
+		location runtime.Location
+	}
+	var (
+		into = (*runtime.Debugger).Step
+		over = (*runtime.Debugger).StepOver
+		out  = (*runtime.Debugger).StepOut
+	)
+
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target)
+		steps   []step
+	}{
+		{"step into", calling, []step{
+			{into, runtime.Location{Label: 0, Statement: 1}},
+			{into, runtime.Location{Label: 1, Statement: 0}},
+			{into, runtime.Location{Label: 1, Statement: 1}},
+			{into, runtime.Location{Label: 0, Statement: 2}},
+		}},
+		{"step over", calling, []step{
+			{over, runtime.Location{Label: 0, Statement: 1}},
+			{over, runtime.Location{Label: 0, Statement: 2}},
+		}},
+		{"step out", calling, []step{
+			{into, runtime.Location{Label: 0, Statement: 1}},
+			{into, runtime.Location{Label: 1, Statement: 0}},
+			{out, runtime.Location{Label: 0, Statement: 2}},
+		}},
+		{"step over in a loop", nested, []step{
+			{over, runtime.Location{Label: 0, Statement: 1}},
+			{over, runtime.Location{Label: 0, Statement: 2}},
+			{over, runtime.Location{Label: 0, Statement: 1}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var c runtime.Target
+			test.program(&c)
+			var debugger = runtime.NewDebugger(&c.Runtime)
+
+			var stop = debugger.Start(context.Background(), true)
+			if stop.Reason != runtime.Entry || stop.Location != (runtime.Location{}) {
+				t.Fatalf("expected to stop on entry, got %v at %v", stop.Reason, stop.Location)
+			}
+			for i, step := range test.steps {
+				stop, err := step.resume(debugger)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stop.Reason != runtime.Step || stop.Location != step.location {
+					t.Fatalf("step %v: expected to stop at %v, got %v at %v", i, step.location, stop.Reason, stop.Location)
+				}
+			}
+			if stop, _ = debugger.Continue(); stop.Reason != runtime.Exited || stop.Err != nil {
+				t.Fatalf("expected the program to exit, got %v %v", stop.Reason, stop.Err)
+			}
+		})
+	}
+
+	t.Run("step out of main", func(t *testing.T) {
+		var c runtime.Target
+		nested(&c)
+		var debugger = runtime.NewDebugger(&c.Runtime)
+		debugger.Start(context.Background(), true)
+		if stop, _ := debugger.StepOut(); stop.Reason != runtime.Exited {
+			t.Fatalf("expected the program to exit, got %v at %v", stop.Reason, stop.Location)
+		}
+		if _, err := debugger.Step(); err != runtime.ErrNotStopped {
+			t.Fatalf("expected ErrNotStopped, got %v", err)
+		}
+	})
+}
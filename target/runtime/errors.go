@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//Throw throws an Value onto the thread-local Errors stack.
+func (t *Target) Throw(value usm.Value) {
+	var v = value.(Value)
+	t.Write(func(r *Runtime) {
+		r.Raise(v.Evaluate(r))
+	})
+}
+
+//Catch removes and returns the latest error on the thread-local error stack.
+//Returns nil if the error stack is empty.
+func (t *Target) Catch() usm.Value {
+	return NewValue(func(r *Runtime) interface{} {
+		return r.effect(func() interface{} {
+			if len(r.Thrown) == 0 {
+				return nil
+			}
+			var err = r.Thrown[len(r.Thrown)-1]
+			r.Thrown = r.Thrown[:len(r.Thrown)-1]
+			return err
+		})
+	})
+}
+
+//Errors returns the number of errors on the thread-local error stack.
+func (t *Target) Errors() usm.Number {
+	return NewValue(func(r *Runtime) interface{} {
+		return big.NewInt(int64(len(r.Thrown)))
+	})
+}
@@ -0,0 +1,69 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestErrors(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target, show usm.Label)
+		output  string
+	}{
+		{"stack", func(c *runtime.Target, show usm.Label) {
+			c.Throw(c.String("a"))
+			c.Throw(c.String("b"))
+			c.JumpTo(show, c.Errors())
+			c.Discard(c.Send(nil, c.Catch()))
+			c.Discard(c.Send(nil, c.Catch()))
+			c.JumpTo(show, c.Errors())
+		}, "2ba0"},
+		{"any value", func(c *runtime.Target, show usm.Label) {
+			c.Throw(number(c, 7))
+			c.JumpTo(show, c.Catch())
+		}, "7"},
+		{"thrown by a function", func(c *runtime.Target, show usm.Label) {
+			var fail = c.Define(0, func() {
+				c.Throw(c.String("failed"))
+			})
+			c.JumpTo(fail)
+			c.JumpTo(show, c.Errors())
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "1failed"},
+		{"host", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Open(c.String("file")))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, runtime.ErrNoOpen.Error()},
+		{"symbol out of range", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Symbol(c.String("a"), number(c, 1)))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "index out of range"},
+		{"undefined function", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(100)
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "undefined function"},
+		{"wrong number of arguments", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(show)
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "wrong number of arguments"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, func(c *runtime.Target) {
+				var show = show(c)
+				c.Main(func() {
+					test.program(c, show)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
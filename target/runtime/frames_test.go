@@ -0,0 +1,72 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+	"github.com/qlova/usm/target/runtime/internal/baseline"
+)
+
+//loopHeavy sums the squares of the numbers below 10000 in a loop.
+func loopHeavy(c usm.Target) {
+	c.Main(func() {
+		var i, sum = c.Var(number(c, 0)), c.Var(number(c, 0))
+		c.Loop(nil, func() {
+			c.If(c.Same(c.Get(i), number(c, 10000)), func() {
+				c.Break()
+			}, nil, nil)
+			c.Set(sum, c.Add(c.Get(sum), c.Mul(c.Get(i), c.Get(i))))
+			c.Set(i, c.Add(c.Get(i), number(c, 1)))
+		})
+	})
+}
+
+//callHeavy computes the 18th Fibonacci number with a recursive function that uses a variable.
+func callHeavy(c usm.Target) {
+	c.Main(func() {
+		const fibonacci = 1
+		c.Define(1, func() {
+			var n = c.Var(c.Get(usm.Arg(0)))
+			c.If(c.Less(c.Get(n), number(c, 2)), func() {
+				c.Return(c.Get(n))
+			}, nil, nil)
+			c.Return(c.Add(
+				c.Call(fibonacci, c.Sub(c.Get(n), number(c, 1))),
+				c.Call(fibonacci, c.Sub(c.Get(n), number(c, 2))),
+			))
+		})
+		c.Discard(c.Call(fibonacci, number(c, 18)))
+	})
+}
+
+//BenchmarkFrames compares register slots with the maps of the baseline interpreter.
+func BenchmarkFrames(b *testing.B) {
+	var programs = []struct {
+		name    string
+		program func(c usm.Target)
+	}{
+		{"loop", loopHeavy},
+		{"calls", callHeavy},
+	}
+	for _, p := range programs {
+		b.Run(p.name+"/slots", func(b *testing.B) {
+			var c runtime.Target
+			p.program(&c)
+			for i := 0; i < b.N; i++ {
+				if err := c.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(p.name+"/maps", func(b *testing.B) {
+			var c baseline.Target
+			p.program(&c)
+			for i := 0; i < b.N; i++ {
+				if err := c.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+//Host is the environment that a Runtime runs in.
+//The zero value uses the streams of the process and has no access to its filesystem.
+type Host struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	//Open resolves a platform-dependent URI to a stream.
+	//The URIs "stdin", "stdout" and "stderr" always resolve to the Host's streams.
+	//If Open is nil, then every other URI is refused with ErrNoOpen.
+	//Set it to OpenFile or OpenFileWritable to give programs access to the filesystem.
+	Open func(uri string) (*Stream, error)
+}
+
+//ErrNoOpen is thrown when a program opens a URI on a Host without an Open function.
+var ErrNoOpen = errors.New("runtime: the host does not open URIs")
+
+//OpenFile opens the file at the path as a read-only Stream.
+func OpenFile(path string) (*Stream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{
+		Reader:  file,
+		Closers: []io.Closer{file},
+	}, nil
+}
+
+//OpenFileWritable opens the file at the path as a Stream.
+//The file is opened for reading and writing if permitted, otherwise it is opened read-only.
+func OpenFileWritable(path string) (*Stream, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if file, err = os.Open(path); err != nil {
+			return nil, err
+		}
+	}
+	return &Stream{
+		Reader:  file,
+		Writer:  file,
+		Closers: []io.Closer{file},
+	}, nil
+}
+
+func (h Host) stdin() io.Reader {
+	if h.Stdin == nil {
+		return os.Stdin
+	}
+	return h.Stdin
+}
+
+func (h Host) stdout() io.Writer {
+	if h.Stdout == nil {
+		return os.Stdout
+	}
+	return h.Stdout
+}
+
+func (h Host) stderr() io.Writer {
+	if h.Stderr == nil {
+		return os.Stderr
+	}
+	return h.Stderr
+}
+
+//open resolves the uri to a stream.
+func (h Host) open(uri string) (*Stream, error) {
+	switch uri {
+	case "stdin":
+		return &Stream{Reader: h.stdin()}, nil
+	case "stdout":
+		return &Stream{Writer: h.stdout()}, nil
+	case "stderr":
+		return &Stream{Writer: h.stderr()}, nil
+	}
+	if h.Open == nil {
+		return nil, ErrNoOpen
+	}
+	return h.Open(uri)
+}
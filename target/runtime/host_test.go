@@ -0,0 +1,85 @@
+package runtime_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestHostOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name   string
+		open   func(uri string) (*runtime.Stream, error)
+		output string
+	}{
+		{"refused by default", nil, runtime.ErrNoOpen.Error() + ";" + runtime.ErrNoOpen.Error()},
+		{"read-only", runtime.OpenFile, "abc;stream is not writable"},
+		{"writable", runtime.OpenFileWritable, "abc;"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var c runtime.Target
+			var output bytes.Buffer
+			c.Host = runtime.Host{Stdout: &output, Open: test.open}
+			c.Main(func() {
+				//open opens the file and runs the body with the stream, or writes the error.
+				var open = func(body func(stream usm.Stream)) {
+					var stream = c.Var(c.Open(c.String(path)))
+					c.If(c.Same(c.Errors(), number(&c, 0)), func() {
+						body(c.Get(stream))
+					}, nil, func() {
+						c.Discard(c.Send(nil, c.Catch()))
+					})
+				}
+				open(func(stream usm.Stream) {
+					var buffer = c.Var(c.Create(number(&c, 3)))
+					c.Discard(c.Read(stream, c.Get(buffer)))
+					c.Discard(c.Send(nil, c.Get(buffer)))
+				})
+				c.Discard(c.Send(nil, c.String(";")))
+				open(func(stream usm.Stream) {
+					c.Discard(c.Send(stream, c.String("x")))
+					c.If(c.More(c.Errors(), number(&c, 0)), func() {
+						c.Discard(c.Send(nil, c.Catch()))
+					}, nil, nil)
+				})
+			})
+			if err := c.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if output.String() != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output.String())
+			}
+		})
+	}
+
+	//Hosts without an Open function still resolve the standard streams.
+	var c runtime.Target
+	var output bytes.Buffer
+	c.Host.Stdout = &output
+	c.Main(func() {
+		c.Discard(c.Send(c.Open(c.String("stdout")), c.String("ok")))
+	})
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "ok" {
+		t.Fatalf("expected %q, got %q", "ok", output.String())
+	}
+}
@@ -0,0 +1,95 @@
+package runtime_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestIf(t *testing.T) {
+	var tests = []struct {
+		name string
+
+		//value is compared against 0 by the If and against 1, 2 ... by each ElseIf in the chain.
+		value  int64
+		chain  int
+		last   bool
+		output string
+	}{
+		{"empty chain, true", 0, 0, false, "0"},
+		{"empty chain, false", 1, 0, false, ""},
+		{"empty chain, else", 1, 0, true, "else"},
+		{"empty chain, true with else", 0, 0, true, "0"},
+		{"short chain", 1, 1, true, "1"},
+		{"long chain, first", 0, 20, true, "0"},
+		{"long chain, middle", 7, 20, true, "7"},
+		{"long chain, end", 20, 20, true, "20"},
+		{"long chain, else", 21, 20, true, "else"},
+		{"long chain, missing last", 21, 20, false, ""},
+		{"long chain, missing last, middle", 13, 20, false, "13"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, runtime.Limits{}, func(c *runtime.Target) {
+				c.Main(func() {
+					var value = c.Var(number(c, test.value))
+					var branch = func(i int) (usm.Bit, usm.Block) {
+						return c.Same(c.Get(value), number(c, int64(i))), func() {
+							c.Discard(c.Send(nil, c.String(strconv.Itoa(i))))
+						}
+					}
+
+					var chain []usm.ElseIf
+					if test.chain > 0 {
+						chain = make([]usm.ElseIf, test.chain)
+					}
+					for i := range chain {
+						chain[i].Bit, chain[i].Block = branch(i + 1)
+					}
+					var last usm.Block
+					if test.last {
+						last = func() {
+							c.Discard(c.Send(nil, c.String("else")))
+						}
+					}
+
+					var condition, body = branch(0)
+					c.If(condition, body, chain, last)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
+
+//TestIfExclusive checks that only the first true branch runs and that the conditions after it are not evaluated.
+func TestIfExclusive(t *testing.T) {
+	output, err := run(t, runtime.Limits{}, func(c *runtime.Target) {
+		c.Main(func() {
+			var send = func(s string) usm.Block {
+				return func() {
+					c.Discard(c.Send(nil, c.String(s)))
+				}
+			}
+			//The last condition faults if it is evaluated.
+			c.If(c.Bit(false), send("if"), []usm.ElseIf{
+				{Bit: c.Bit(true), Block: send("first")},
+				{Bit: c.Bit(true), Block: send("second")},
+				{Bit: c.Less(c.String("not a number"), number(c, 1)), Block: send("third")},
+			}, send("else"))
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "first" {
+		t.Fatalf("expected %q, got %q", "first", output)
+	}
+}
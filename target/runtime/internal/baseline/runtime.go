@@ -0,0 +1,97 @@
+//Package baseline is the runtime interpreter as it was before frames were given register slots,
+//it keeps every variable of a call in a map and is only used to benchmark against.
+//It is completed only as far as the benchmark programs need: Set, arithmetic, Break, arguments
+//and blocks that share the variables of their function.
+package baseline
+
+import "github.com/qlova/usm"
+
+//Value is a runtime usm.Value
+type Value func() interface{}
+
+//Block is a runtime usm.Block
+type Block struct {
+	Function bool
+
+	Statements []func()
+}
+
+//RunWith runs a block with the given runtime.
+func (block Block) RunWith(r *Runtime, args ...interface{}) error {
+	if len(block.Statements) == 0 {
+		return nil
+	}
+
+	r.Push(block.Function)
+	defer r.Pop()
+
+	if block.Function {
+		r.Args = args
+	}
+	for {
+		if r.ProgramCounter >= len(block.Statements) {
+			return nil
+		}
+		block.Statements[r.ProgramCounter]()
+		r.ProgramCounter++
+
+		if r.Returning || r.Breaking {
+			if block.Function {
+				r.Returning = false
+			}
+			return nil
+		}
+	}
+}
+
+//Runtime is a runtime object for a runtime `u` target.
+type Runtime struct {
+	Scope
+	Scopes []Scope
+
+	Current, Entrypoint *Block
+	Blocks              []Block
+
+	ReturnValue interface{}
+	Returning   bool
+	Breaking    bool
+}
+
+//Push pushes a new scope, blocks that are not functions share the variables of the current scope.
+func (r *Runtime) Push(function bool) {
+	r.Scopes = append(r.Scopes, r.Scope)
+	if function {
+		r.Scope = NewScope()
+	} else {
+		r.Scope = Scope{Args: r.Args, Variables: r.Variables}
+	}
+}
+
+//Pop pops the last scope.
+func (r *Runtime) Pop() {
+	r.Scope = r.Scopes[len(r.Scopes)-1]
+	r.Scopes = r.Scopes[:len(r.Scopes)-1]
+}
+
+//Run runs the runtime.
+func (r *Runtime) Run() error {
+	r.Scope = NewScope()
+	r.Scopes = nil
+
+	return r.Entrypoint.RunWith(r)
+}
+
+//Scope is the current scope.
+type Scope struct {
+	ProgramCounter int
+
+	Args      []interface{}
+	Variables map[usm.Register]interface{}
+}
+
+//NewScope returns a new scope.
+func NewScope() Scope {
+	return Scope{
+		Variables: make(map[usm.Register]interface{}),
+	}
+}
@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/qlova/usm"
+)
+
+//ThrownError is returned by Invoke, or by the stream of a Fork, when the function leaves errors on the error stack.
+type ThrownError struct {
+	Values []interface{}
+}
+
+func (e *ThrownError) Error() string {
+	var messages = make([]string, len(e.Values))
+	for i, value := range e.Values {
+		if data, ok := value.([]byte); ok {
+			messages[i] = string(data)
+		} else {
+			messages[i] = Kind(value)
+		}
+	}
+	return "runtime: thrown: " + strings.Join(messages, ", ")
+}
+
+//Invoke calls the function at the label with the Go arguments and returns the result as a Go value.
+//The arguments are converted with Marshal and the result is converted with Unmarshal into an interface{}.
+//Errors that the function throws and does not catch are removed from the error stack and returned as a *ThrownError.
+func (r *Runtime) Invoke(label usm.Label, args ...interface{}) (interface{}, error) {
+	var result interface{}
+	if err := r.InvokeInto(&result, label, args...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//InvokeInto is like Invoke, except that the result is converted into the value that result points to.
+func (r *Runtime) InvokeInto(result interface{}, label usm.Label, args ...interface{}) (err error) {
+	var out = reflect.ValueOf(result)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return fmt.Errorf("runtime.Invoke: result must be a non-nil pointer, not %T", result)
+	}
+
+	if label < 1 || int(label) > len(r.Blocks) {
+		return fmt.Errorf("runtime.Invoke: undefined label %v", label)
+	}
+	var block = r.Blocks[label-1]
+	if block.Native == nil && len(args) != block.Arguments {
+		return fmt.Errorf("runtime.Invoke: label %v expects %v arguments but got %v", label, block.Arguments, len(args))
+	}
+
+	var converted = make([]interface{}, len(args))
+	for i, arg := range args {
+		if converted[i], err = Marshal(arg); err != nil {
+			return fmt.Errorf("runtime.Invoke: argument %v: %w", i, err)
+		}
+	}
+
+	var thrown = len(r.Thrown)
+	var value interface{}
+
+	if err := r.invoke(label, converted, &value); err != nil {
+		return err
+	}
+
+	if len(r.Thrown) > thrown {
+		var values = append([]interface{}{}, r.Thrown[thrown:]...)
+		r.Thrown = r.Thrown[:thrown]
+		return &ThrownError{Values: values}
+	}
+
+	v, err := Unmarshal(value, out.Elem().Type())
+	if err != nil {
+		return fmt.Errorf("runtime.Invoke: result: %w", err)
+	}
+	out.Elem().Set(v)
+	return nil
+}
+
+//invoke jumps to the label, recovering from a Halt.
+func (r *Runtime) invoke(label usm.Label, args []interface{}, result *interface{}) (err error) {
+	var scope, scopes = r.Scope, len(r.Scopes)
+	defer func() {
+		r.Scope, r.Scopes = scope, r.Scopes[:scopes]
+	}()
+	defer r.rescue(&err)
+
+	r.Jump(label, args...)
+	*result, r.ReturnValue = r.ReturnValue, nil
+	return nil
+}
@@ -0,0 +1,147 @@
+package runtime_test
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestInvoke(t *testing.T) {
+	var c runtime.Target
+	var add = c.Define(2, func() {
+		c.Return(c.Add(c.Get(usm.Arg(0)), c.Get(usm.Arg(1))))
+	})
+	var greet = c.Define(1, func() {
+		c.Return(c.Concat(c.String("hello "), c.Get(usm.Arg(0))))
+	})
+	var fail = c.Define(0, func() {
+		c.Throw(c.String("failed"))
+		c.Throw(c.String("again"))
+	})
+	var r = c.NewRuntime()
+
+	t.Run("result", func(t *testing.T) {
+		result, err := r.Invoke(add, 1, big.NewInt(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if number, ok := result.(*big.Int); !ok || number.Int64() != 3 {
+			t.Fatalf("expected 3, got %v", result)
+		}
+	})
+
+	t.Run("into", func(t *testing.T) {
+		var sum int8
+		if err := r.InvokeInto(&sum, add, 2, uint(3)); err != nil {
+			t.Fatal(err)
+		}
+		var greeting string
+		if err := r.InvokeInto(&greeting, greet, "usm"); err != nil {
+			t.Fatal(err)
+		}
+		if sum != 5 || greeting != "hello usm" {
+			t.Fatalf("got %v and %q", sum, greeting)
+		}
+	})
+
+	t.Run("thrown", func(t *testing.T) {
+		var _, err = r.Invoke(fail)
+		var thrown *runtime.ThrownError
+		if !errors.As(err, &thrown) {
+			t.Fatalf("expected a ThrownError, got %v", err)
+		}
+		if expected := []interface{}{[]byte("failed"), []byte("again")}; !reflect.DeepEqual(thrown.Values, expected) {
+			t.Fatalf("expected %q, got %q", expected, thrown.Values)
+		}
+		if len(r.Thrown) != 0 {
+			t.Fatalf("the errors were left on the error stack: %q", r.Thrown)
+		}
+	})
+
+	var errs = []struct {
+		name   string
+		invoke func() error
+	}{
+		{"unsupported argument", func() error {
+			var _, err = r.Invoke(add, 1, struct{}{})
+			return err
+		}},
+		{"unsupported map key", func() error {
+			var _, err = r.Invoke(greet, map[int]string{})
+			return err
+		}},
+		{"mismatched result", func() error {
+			var sum string
+			return r.InvokeInto(&sum, add, 1, 2)
+		}},
+		{"overflowing result", func() error {
+			var sum int8
+			return r.InvokeInto(&sum, add, 100, 100)
+		}},
+		{"result is not a pointer", func() error {
+			var sum int
+			return r.InvokeInto(sum, add, 1, 2)
+		}},
+		{"wrong number of arguments", func() error {
+			var _, err = r.Invoke(add, 1)
+			return err
+		}},
+		{"undefined label", func() error {
+			var _, err = r.Invoke(usm.Label(100))
+			return err
+		}},
+	}
+	for _, test := range errs {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.invoke(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewRuntimeParallel(t *testing.T) {
+	var c runtime.Target
+	//sum returns 1+2+...+n, plus n from an array so that each runtime holds its own collections.
+	var sum = c.Define(1, func() {
+		var total = c.Var(number(&c, 0))
+		c.Range(number(&c, 1), -1, c.Get(usm.Arg(0)), number(&c, 1), func(i usm.Number) {
+			c.Set(total, c.Add(c.Get(total), i))
+		})
+		var array = c.Var(c.Array(c.Get(usm.Arg(0))))
+		c.Return(c.Add(c.Get(total), c.Index(c.Get(array), number(&c, 0))))
+	})
+
+	var wg sync.WaitGroup
+	var errs = make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var r = c.NewRuntime()
+			for n := int64(i * 10); n < int64(i*10+50); n++ {
+				var result int64
+				if err := r.InvokeInto(&result, sum, n); err != nil {
+					errs[i] = err
+					return
+				}
+				if expected := n*(n+1)/2 + n; result != expected {
+					errs[i] = fmt.Errorf("sum(%v): expected %v, got %v", n, expected, result)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
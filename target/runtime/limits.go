@@ -0,0 +1,179 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	goruntime "runtime"
+	"sync/atomic"
+)
+
+//Limits restricts the resources that a Runtime may use.
+//A zero limit is unlimited, except for Depth.
+//Statements and Memory are shared by a Runtime and the runtimes that it forks, so forking does not add to them.
+type Limits struct {
+	//Statements is the maximum number of statements to execute.
+	Statements int64
+
+	//Depth is the maximum depth of nested function calls, DefaultDepth if zero.
+	//Deeper calls would overflow the Go stack, which cannot be recovered from.
+	Depth int
+
+	//Memory is the maximum number of bytes to allocate for strings, arrays, tables, pointers and large numbers.
+	Memory int64
+}
+
+//DefaultDepth is the depth limit of a Runtime with a zero Limits.Depth.
+const DefaultDepth = 10000
+
+//ForkSize is the memory counted for each forked runtime, about the size of a goroutine and its pipes.
+const ForkSize = 8 << 10
+
+//budget counts the statements executed and the bytes allocated by a Runtime and the runtimes that it forks.
+//Forks run concurrently, so it is only accessed atomically.
+type budget struct {
+	executed, allocated int64
+}
+
+//budget returns the budget of the runtime, which is shared with its forks.
+func (r *Runtime) budget() *budget {
+	if r.shared == nil {
+		r.shared = &budget{executed: r.Executed, allocated: r.Allocated}
+	}
+	return r.shared
+}
+
+//depth returns the maximum depth of nested function calls.
+func (l Limits) depth() int {
+	if l.Depth <= 0 {
+		return DefaultDepth
+	}
+	return l.Depth
+}
+
+//Limit identifies one of the Limits.
+type Limit int
+
+//These are the limits that can be exceeded.
+const (
+	StatementLimit Limit = iota + 1
+	DepthLimit
+	MemoryLimit
+)
+
+func (l Limit) String() string {
+	switch l {
+	case StatementLimit:
+		return "statement"
+	case DepthLimit:
+		return "depth"
+	case MemoryLimit:
+		return "memory"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(l))
+	}
+}
+
+//LimitError is returned by Run when a Runtime exceeds one of its Limits.
+type LimitError struct {
+	Limit Limit
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("runtime: %v limit of %v exceeded", e.Limit, e.Value)
+}
+
+//FaultError is returned by Run when the program is ill-formed, such as an operation on a value of the wrong type
+//or a register that the function does not have.
+type FaultError struct {
+	Err error
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("runtime: fault: %v", e.Err)
+}
+
+func (e *FaultError) Unwrap() error {
+	return e.Err
+}
+
+//halt is panicked to unwind a Runtime that must stop.
+type halt struct {
+	error
+}
+
+//Halt stops the runtime, Run will return the given error.
+func (r *Runtime) Halt(err error) {
+	panic(halt{err})
+}
+
+//rescue recovers from a Halt and sets err.
+//Faults of the Go runtime, which ill-formed programs cause, are recovered as a *FaultError.
+//Must be deferred directly.
+func (r *Runtime) rescue(err *error) {
+	if v := recover(); v != nil {
+		switch v := v.(type) {
+		case halt:
+			*err = v.error
+		case goruntime.Error:
+			*err = &FaultError{v}
+		default:
+			panic(v)
+		}
+		r.Returning, r.Breaking = false, false
+	}
+}
+
+//step counts an executed statement, halting the runtime if it should stop.
+func (r *Runtime) step() {
+	r.Executed++
+	if executed := atomic.AddInt64(&r.budget().executed, 1); r.Limits.Statements > 0 && executed > r.Limits.Statements {
+		r.Halt(&LimitError{StatementLimit, r.Limits.Statements})
+	}
+	if r.context != nil && r.Executed%1024 == 0 {
+		select {
+		case <-r.context.Done():
+			r.Halt(r.context.Err())
+		default:
+		}
+	}
+}
+
+//Allocate counts the allocation of the given number of bytes, halting the runtime if it exceeds its memory limit.
+func (r *Runtime) Allocate(bytes int64) {
+	r.Allocated += bytes
+	if allocated := atomic.AddInt64(&r.budget().allocated, bytes); r.Limits.Memory > 0 && allocated > r.Limits.Memory {
+		r.Halt(&LimitError{MemoryLimit, r.Limits.Memory})
+	}
+}
+
+//AllocateNumber counts the allocation of a number with the given number of bits, before it is computed.
+//Numbers that fit in a machine word are not counted.
+func (r *Runtime) AllocateNumber(bits int64) {
+	if bits > 64 {
+		r.Allocate(bits / 8)
+	}
+}
+
+//RunContext runs the runtime until it finishes, the context is done or one of its Limits is exceeded.
+//Streams that block the runtime are not interrupted by the context.
+//After a Restore, the runtime resumes from its snapshot instead of starting again.
+func (r *Runtime) RunContext(ctx context.Context) (err error) {
+	r.Scope = Scope{}
+	r.Scopes = nil
+	r.context = ctx
+	r.Depth = 0
+	if r.resume == nil {
+		r.Executed, r.Allocated = 0, 0
+	}
+	r.shared = nil
+	defer func() { r.resume = nil }()
+	defer r.Close()
+	defer r.rescue(&err)
+
+	if r.Tracer != nil {
+		r.Tracer.start()
+		defer r.Tracer.stop()
+	}
+	return r.Entrypoint.RunWith(r)
+}
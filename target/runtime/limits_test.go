@@ -0,0 +1,118 @@
+package runtime_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestFaults(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target)
+	}{
+		{"wrong type", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Add(c.String("1"), number(c, 1)))
+			})
+		}},
+		{"missing argument", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Get(usm.Arg(3)))
+			})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var _, err = run(t, runtime.Limits{}, test.program)
+			var fault *runtime.FaultError
+			if !errors.As(err, &fault) {
+				t.Fatalf("expected a *runtime.FaultError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var tests = []struct {
+		name    string
+		limits  runtime.Limits
+		limit   runtime.Limit
+		program func(c *runtime.Target)
+	}{
+		{"statements", runtime.Limits{Statements: 1000}, runtime.StatementLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				c.Loop(nil, func() {})
+			})
+		}},
+		{"default depth", runtime.Limits{}, runtime.DepthLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				var forever = c.Define(0, func() {
+					c.JumpTo(1)
+				})
+				c.JumpTo(forever)
+			})
+		}},
+		{"power", runtime.Limits{Memory: 1 << 20}, runtime.MemoryLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Pow(number(c, 10), number(c, 100000000)))
+			})
+		}},
+		{"product", runtime.Limits{Memory: 1 << 20}, runtime.MemoryLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				var n = c.Var(number(c, 1<<62))
+				c.Loop(nil, func() {
+					c.Set(n, c.Mul(c.Get(n), c.Get(n)))
+				})
+			})
+		}},
+		{"table", runtime.Limits{Memory: 1 << 20}, runtime.MemoryLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				var table = c.Var(c.Table(nil))
+				var key = c.Var(c.String(""))
+				c.Loop(nil, func() {
+					c.Set(key, c.Concat(c.Get(key), c.String("k")))
+					c.Insert(c.Get(table), c.Get(key), c.Bit(true))
+				})
+			})
+		}},
+		{"forked statements", runtime.Limits{Statements: 10000}, runtime.StatementLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				//each fork stays within the limit, together they exceed it.
+				var count = c.Define(0, func() {
+					c.Range(number(c, 0), -2, number(c, 3000), number(c, 1), func(i usm.Number) {})
+				})
+				var streams []usm.Register
+				for i := 0; i < 4; i++ {
+					streams = append(streams, c.Var(c.Fork(count)))
+				}
+				for _, stream := range streams {
+					c.Discard(c.Read(c.Get(stream), c.Create(number(c, 1))))
+				}
+				//the forks have finished, so this statement is counted after them.
+				c.Discard(number(c, 0))
+			})
+		}},
+		{"forked memory", runtime.Limits{Memory: 100 * runtime.ForkSize}, runtime.MemoryLimit, func(c *runtime.Target) {
+			c.Main(func() {
+				var wait = c.Define(0, func() {
+					c.Discard(c.Read(nil, c.Create(number(c, 1))))
+				})
+				c.Loop(nil, func() {
+					c.Discard(c.Fork(wait))
+				})
+			})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var _, err = run(t, test.limits, test.program)
+			var limit *runtime.LimitError
+			if !errors.As(err, &limit) || limit.Limit != test.limit {
+				t.Fatalf("expected the %v limit, got %v", test.limit, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//Truth returns true if the Bit is true or the Number is not zero.
+func Truth(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case *big.Int:
+		return v.Sign() != 0
+	default:
+		return false
+	}
+}
+
+//Loop loops the body while an optional condition is true.
+//If condition is nil, then the loop is infinite.
+func (t *Target) Loop(condition usm.Number, body usm.Block) {
+	var block = t.Block(body)
+
+	if condition == nil {
+		t.Write(func(r *Runtime) {
+			for r.Loop(block) {
+			}
+		})
+		return
+	}
+
+	var c = condition.(Value)
+	t.Write(func(r *Runtime) {
+		if r.resuming(block) && !r.Loop(block) {
+			return
+		}
+		for Truth(c.Evaluate(r)) && r.Loop(block) {
+		}
+	})
+}
+
+//Break breaks the inner-most loop.
+func (t *Target) Break() {
+	t.Write(func(r *Runtime) {
+		r.Breaking = true
+	})
+}
+
+//Range creates a loop that runs the iterator from 'from' to 'to'
+//under the relationship constraint with a given step.
+//Relationship -2: <, -1:<=, 0: =, 1: >=, 2: >
+func (t *Target) Range(from usm.Number, relationship int, to usm.Number, step usm.Number,
+	body func(i usm.Number)) {
+
+	if relationship < -2 || relationship > 2 {
+		panic(fmt.Sprintf("runtime.Range: invalid relationship %v", relationship))
+	}
+
+	var f, l, s = from.(Value), to.(Value), step.(Value)
+
+	//The loop state is kept in registers so that it belongs to the frame.
+	var iterator, limit, increment = t.register(), t.register(), t.register()
+	var block = t.Block(func() {
+		body(t.Get(iterator))
+	})
+
+	var within = func(r *Runtime) bool {
+		var cmp = r.Variables[iterator-1].(*big.Int).Cmp(r.Variables[limit-1].(*big.Int))
+		switch relationship {
+		case -2:
+			return cmp < 0
+		case -1:
+			return cmp <= 0
+		case 0:
+			return cmp == 0
+		case 1:
+			return cmp >= 0
+		default:
+			return cmp > 0
+		}
+	}
+
+	var advance = func(r *Runtime) {
+		r.Variables[iterator-1] = new(big.Int).Add(
+			r.Variables[iterator-1].(*big.Int),
+			r.Variables[increment-1].(*big.Int),
+		)
+	}
+
+	t.Write(func(r *Runtime) {
+		if r.resuming(block) {
+			if !r.Loop(block) {
+				return
+			}
+			advance(r)
+		} else {
+			r.Variables[iterator-1] = f.Evaluate(r)
+			r.Variables[limit-1] = l.Evaluate(r)
+			r.Variables[increment-1] = s.Evaluate(r)
+		}
+
+		for within(r) && r.Loop(block) {
+			advance(r)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestLoops(t *testing.T) {
+	//ranged returns a program that shows each iterator of a Range.
+	var ranged = func(from int64, relationship int, to, step int64) func(c *runtime.Target) {
+		return func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				c.Range(number(c, from), relationship, number(c, to), number(c, step), func(i usm.Number) {
+					c.JumpTo(show, i)
+				})
+			})
+		}
+	}
+
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target)
+		output  string
+	}{
+		{"range less", ranged(0, -2, 3, 1), "012"},
+		{"range less or same", ranged(0, -1, 3, 1), "0123"},
+		{"range same", ranged(2, 0, 2, 1), "2"},
+		{"range more or same", ranged(3, 1, 0, -1), "3210"},
+		{"range more", ranged(3, 2, 0, -1), "321"},
+		{"range step", ranged(0, -2, 7, 3), "036"},
+		{"range never", ranged(3, -2, 0, 1), ""},
+		{"conditional loop", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				var i = c.Var(number(c, 0))
+				c.Loop(c.Less(c.Get(i), number(c, 3)), func() {
+					c.JumpTo(show, c.Get(i))
+					c.Set(i, c.Add(c.Get(i), number(c, 1)))
+				})
+			})
+		}, "012"},
+		{"break loop", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				var i = c.Var(number(c, 0))
+				c.Loop(nil, func() {
+					c.If(c.Same(c.Get(i), number(c, 3)), func() {
+						c.Break()
+					}, nil, nil)
+					c.JumpTo(show, c.Get(i))
+					c.Set(i, c.Add(c.Get(i), number(c, 1)))
+				})
+			})
+		}, "012"},
+		{"break range", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				c.Range(number(c, 0), -2, number(c, 10), number(c, 1), func(i usm.Number) {
+					c.If(c.Same(i, number(c, 2)), func() {
+						c.Break()
+					}, nil, nil)
+					c.JumpTo(show, i)
+				})
+				c.Discard(c.Send(nil, c.String(";")))
+			})
+		}, "01;"},
+		{"break inner", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				c.Range(number(c, 0), -2, number(c, 2), number(c, 1), func(i usm.Number) {
+					c.Range(number(c, 0), -2, number(c, 10), number(c, 1), func(j usm.Number) {
+						c.If(c.Same(j, number(c, 1)), func() {
+							c.Break()
+						}, nil, nil)
+						c.JumpTo(show, i)
+					})
+				})
+			})
+		}, "01"},
+		{"each", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				c.Each(c.Array(number(c, 4), number(c, 5), number(c, 6)), func(i usm.Number, v usm.Value) {
+					c.JumpTo(show, i)
+					c.JumpTo(show, v)
+				})
+			})
+		}, "041526"},
+		{"break each", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				c.Each(c.Array(number(c, 4), number(c, 5), number(c, 6)), func(i usm.Number, v usm.Value) {
+					c.If(c.Same(i, number(c, 1)), func() {
+						c.Break()
+					}, nil, nil)
+					c.JumpTo(show, v)
+				})
+			})
+		}, "4"},
+		{"each appending", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				var array = c.Var(c.Array(number(c, 1), number(c, 2), number(c, 3)))
+				c.Each(c.Get(array), func(i usm.Number, v usm.Value) {
+					c.Discard(c.Append(c.Get(array), v))
+					c.JumpTo(show, v)
+				})
+				c.JumpTo(show, c.Count(c.Get(array)))
+			})
+		}, "1236"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, test.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
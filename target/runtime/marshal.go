@@ -0,0 +1,322 @@
+package runtime
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+var (
+	bigIntType    = reflect.TypeOf((*big.Int)(nil))
+	bytesType     = reflect.TypeOf([]byte(nil))
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	runtimeType   = reflect.TypeOf((*Runtime)(nil))
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+//Marshal converts a Go value into a runtime value.
+//Integers, *big.Int, strings, []byte, bools, slices, arrays and maps with string keys are supported,
+//runtime values such as *Array, *Table and Function are passed through unchanged.
+//Values that contain themselves cannot be converted and return an error.
+func Marshal(value interface{}) (interface{}, error) {
+	return marshaller{}.marshal(value)
+}
+
+//marshaller holds the Go slices, maps and pointers that are being converted, so that cycles are detected.
+type marshaller map[visit]bool
+
+//visit identifies a Go slice, map or pointer by its address, slices also by their length.
+type visit struct {
+	typ     reflect.Type
+	pointer uintptr
+	length  int
+}
+
+//enter marks the value as being converted, leave must be called once it has been converted.
+func (m marshaller) enter(value reflect.Value) (visit, error) {
+	var v = visit{typ: value.Type(), pointer: value.Pointer()}
+	if value.Kind() == reflect.Slice {
+		v.length = value.Len()
+	}
+	if m[v] {
+		return v, fmt.Errorf("runtime.Marshal: cannot convert %v that contains itself", value.Type())
+	}
+	m[v] = true
+	return v, nil
+}
+
+func (m marshaller) leave(v visit) {
+	delete(m, v)
+}
+
+func (m marshaller) marshal(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *big.Int:
+		return new(big.Int).Set(v), nil
+	case []byte:
+		return append([]byte{}, v...), nil
+	case string:
+		return []byte(v), nil
+	case bool:
+		return v, nil
+	case *Array, *Table, *Pointer, *Stream, Function:
+		return v, nil
+	}
+	return m.value(reflect.ValueOf(value))
+}
+
+func (m marshaller) value(value reflect.Value) (interface{}, error) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(value.Uint()), nil
+	case reflect.String:
+		return []byte(value.String()), nil
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice {
+			v, err := m.enter(value)
+			if err != nil {
+				return nil, err
+			}
+			defer m.leave(v)
+		}
+		var array = &Array{Values: make([]interface{}, value.Len())}
+		for i := range array.Values {
+			element, err := m.marshal(value.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("index %v: %w", i, err)
+			}
+			array.Values[i] = element
+		}
+		return array, nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("runtime.Marshal: unsupported map key type %v", value.Type().Key())
+		}
+		v, err := m.enter(value)
+		if err != nil {
+			return nil, err
+		}
+		defer m.leave(v)
+		var table = &Table{Values: make(map[string]interface{}, value.Len())}
+		var iter = value.MapRange()
+		for iter.Next() {
+			element, err := m.marshal(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", iter.Key().String(), err)
+			}
+			table.Values[iter.Key().String()] = element
+		}
+		return table, nil
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil, nil
+		}
+		v, err := m.enter(value)
+		if err != nil {
+			return nil, err
+		}
+		defer m.leave(v)
+		return m.marshal(value.Elem().Interface())
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return m.marshal(value.Elem().Interface())
+	}
+	return nil, fmt.Errorf("runtime.Marshal: unsupported type %v", value.Type())
+}
+
+//Unmarshal converts a runtime value into a Go value of the given type.
+//When the type is interface{}, Numbers become *big.Int, Strings become string, Bits become bool,
+//Arrays become []interface{} and Tables become map[string]interface{}.
+//Pointers are converted into Go pointers to their value.
+//Values that contain themselves cannot be converted and return an error.
+func Unmarshal(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	return unmarshaller{}.unmarshal(value, typ)
+}
+
+//unmarshaller holds the Arrays, Tables and Pointers that are being converted, so that cycles are detected.
+type unmarshaller map[interface{}]bool
+
+//enter marks the value as being converted, leave must be called once it has been converted.
+func (u unmarshaller) enter(value interface{}) error {
+	if u[value] {
+		return fmt.Errorf("runtime.Unmarshal: cannot convert %v that contains itself", Kind(value))
+	}
+	u[value] = true
+	return nil
+}
+
+func (u unmarshaller) leave(value interface{}) {
+	delete(u, value)
+}
+
+func (u unmarshaller) unmarshal(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	var result = reflect.New(typ).Elem()
+
+	if value == nil {
+		return result, nil
+	}
+
+	var mismatch = func() (reflect.Value, error) {
+		return result, fmt.Errorf("runtime.Unmarshal: cannot convert %v to %v", Kind(value), typ)
+	}
+
+	if typ == bigIntType {
+		number, ok := value.(*big.Int)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(new(big.Int).Set(number)), nil
+	}
+	if typ == bytesType {
+		data, ok := value.([]byte)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(append([]byte{}, data...)), nil
+	}
+	if reflect.TypeOf(value).AssignableTo(typ) && typ != interfaceType {
+		result.Set(reflect.ValueOf(value))
+		return result, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := value.(*big.Int)
+		if !ok {
+			return mismatch()
+		}
+		if !number.IsInt64() || result.OverflowInt(number.Int64()) {
+			return result, fmt.Errorf("runtime.Unmarshal: %v overflows %v", number, typ)
+		}
+		result.SetInt(number.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, ok := value.(*big.Int)
+		if !ok {
+			return mismatch()
+		}
+		if !number.IsUint64() || result.OverflowUint(number.Uint64()) {
+			return result, fmt.Errorf("runtime.Unmarshal: %v overflows %v", number, typ)
+		}
+		result.SetUint(number.Uint64())
+	case reflect.String:
+		data, ok := value.([]byte)
+		if !ok {
+			return mismatch()
+		}
+		result.SetString(string(data))
+	case reflect.Bool:
+		bit, ok := value.(bool)
+		if !ok {
+			return mismatch()
+		}
+		result.SetBool(bit)
+	case reflect.Slice:
+		array, ok := value.(*Array)
+		if !ok {
+			return mismatch()
+		}
+		if err := u.enter(array); err != nil {
+			return result, err
+		}
+		defer u.leave(array)
+		result.Set(reflect.MakeSlice(typ, len(array.Values), len(array.Values)))
+		for i, element := range array.Values {
+			converted, err := u.unmarshal(element, typ.Elem())
+			if err != nil {
+				return result, fmt.Errorf("index %v: %w", i, err)
+			}
+			result.Index(i).Set(converted)
+		}
+	case reflect.Map:
+		table, ok := value.(*Table)
+		if !ok || typ.Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		if err := u.enter(table); err != nil {
+			return result, err
+		}
+		defer u.leave(table)
+		result.Set(reflect.MakeMapWithSize(typ, len(table.Values)))
+		for key, element := range table.Values {
+			converted, err := u.unmarshal(element, typ.Elem())
+			if err != nil {
+				return result, fmt.Errorf("key %q: %w", key, err)
+			}
+			result.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), converted)
+		}
+	case reflect.Ptr:
+		pointer, ok := value.(*Pointer)
+		if !ok {
+			return mismatch()
+		}
+		if err := u.enter(pointer); err != nil {
+			return result, err
+		}
+		defer u.leave(pointer)
+		converted, err := u.unmarshal(pointer.Value, typ.Elem())
+		if err != nil {
+			return result, err
+		}
+		result.Set(reflect.New(typ.Elem()))
+		result.Elem().Set(converted)
+	case reflect.Interface:
+		if typ.NumMethod() > 0 {
+			return mismatch()
+		}
+		switch v := value.(type) {
+		case []byte:
+			result.Set(reflect.ValueOf(string(v)))
+		case *Array:
+			converted, err := u.unmarshal(v, reflect.TypeOf([]interface{}(nil)))
+			if err != nil {
+				return result, err
+			}
+			result.Set(converted)
+		case *Table:
+			converted, err := u.unmarshal(v, reflect.TypeOf(map[string]interface{}(nil)))
+			if err != nil {
+				return result, err
+			}
+			result.Set(converted)
+		default:
+			result.Set(reflect.ValueOf(value))
+		}
+	default:
+		return mismatch()
+	}
+	return result, nil
+}
+
+//Kind returns the usm name for the kind of the runtime value.
+func Kind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bit"
+	case *big.Int:
+		return "number"
+	case []byte:
+		return "string"
+	case *Array:
+		return "array"
+	case *Table:
+		return "table"
+	case *Pointer:
+		return "pointer"
+	case *Stream:
+		return "stream"
+	case Function:
+		return "function"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,131 @@
+package runtime_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestUnmarshalCycles(t *testing.T) {
+	var array = &runtime.Array{Values: []interface{}{nil}}
+	array.Values[0] = array
+
+	var table = &runtime.Table{Values: map[string]interface{}{}}
+	table.Values["self"] = &runtime.Array{Values: []interface{}{table}}
+
+	var pointer = &runtime.Pointer{}
+	pointer.Value = pointer
+
+	var tests = []struct {
+		name  string
+		value interface{}
+		typ   interface{}
+	}{
+		{"array", array, []interface{}{}},
+		{"array as interface", array, new(interface{})},
+		{"table through array", table, map[string]interface{}{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var typ = reflect.TypeOf(test.typ)
+			if typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			if _, err := runtime.Unmarshal(test.value, typ); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	t.Run("pointer to pointer", func(t *testing.T) {
+		type cycle *cycle
+		if _, err := runtime.Unmarshal(pointer, reflect.TypeOf(cycle(nil))); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestUnmarshalShared(t *testing.T) {
+	var shared = &runtime.Array{Values: []interface{}{[]byte("a")}}
+	var value = &runtime.Array{Values: []interface{}{shared, shared}}
+
+	result, err := runtime.Unmarshal(value, reflect.TypeOf([][]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Interface().([][]string); !reflect.DeepEqual(got, [][]string{{"a"}, {"a"}}) {
+		t.Fatalf("got %v", got)
+	}
+
+	result, err = runtime.Unmarshal(&runtime.Pointer{Value: []byte("b")}, reflect.TypeOf((*string)(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *result.Interface().(*string); got != "b" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type name string
+	type flag bool
+	type names []name
+
+	var shared = []int{1}
+
+	var tests = []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"named string", name("a"), "a"},
+		{"named bool", flag(true), true},
+		{"named slice", names{"a", "b"}, []string{"a", "b"}},
+		{"shared slice", [][]int{shared, shared}, [][]int{{1}, {1}}},
+		{"map", map[string]int{"a": 1}, map[string]int{"a": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := runtime.Marshal(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := runtime.Unmarshal(value, reflect.TypeOf(test.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.Interface(); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestMarshalCycles(t *testing.T) {
+	var slice = []interface{}{nil}
+	slice[0] = slice
+
+	var table = map[string]interface{}{}
+	table["self"] = []interface{}{table}
+
+	type cycle *cycle
+	var pointer cycle
+	pointer = &pointer
+
+	var tests = []struct {
+		name  string
+		value interface{}
+	}{
+		{"slice", slice},
+		{"map through slice", table},
+		{"pointer", pointer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := runtime.Marshal(test.value); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
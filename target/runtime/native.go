@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/qlova/usm"
+)
+
+//NativeFunction is a Go function that can be called from usm code.
+type NativeFunction func(r *Runtime, args []interface{}) interface{}
+
+//Register registers the Go function under the given name and returns its label.
+//usm code can then Call the label directly, or obtain the function as a value with Native([]byte(name)).
+//
+//The function's parameters and results are converted with Unmarshal and Marshal.
+//A first parameter of type *Runtime receives the calling runtime.
+//A final result of type error is thrown when it is not nil.
+func (t *Target) Register(name string, function interface{}) (usm.Label, error) {
+	native, err := wrap(function)
+	if err != nil {
+		return 0, fmt.Errorf("runtime.Register %v: %w", name, err)
+	}
+	if _, ok := t.Natives[name]; ok {
+		return 0, fmt.Errorf("runtime.Register %v: already registered", name)
+	}
+
+	t.Labels++
+	t.Blocks = append(t.Blocks, Block{Label: usm.Label(len(t.Blocks) + 1), Native: native})
+
+	if t.Natives == nil {
+		t.Natives = make(map[string]usm.Label)
+	}
+	t.Natives[name] = usm.Label(len(t.Blocks))
+	return t.Natives[name], nil
+}
+
+//Native returns the registered function with the given name as a Function value.
+//An error is thrown if the name was not registered before the program was built.
+func (t *Target) Native(name []byte) usm.Native {
+	var label, ok = t.Natives[string(name)]
+	var err = []byte("undefined native: " + string(name))
+	return NewValue(func(r *Runtime) interface{} {
+		if !ok {
+			r.Raise(err)
+			return nil
+		}
+		return Function{Label: label}
+	})
+}
+
+//wrap converts a Go function into a NativeFunction.
+func wrap(function interface{}) (NativeFunction, error) {
+	switch native := function.(type) {
+	case NativeFunction:
+		return native, nil
+	case func(r *Runtime, args []interface{}) interface{}:
+		return native, nil
+	}
+
+	var value = reflect.ValueOf(function)
+	if value.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%T is not a function", function)
+	}
+	var typ = value.Type()
+
+	var offset int
+	if typ.NumIn() > 0 && typ.In(0) == runtimeType {
+		offset = 1
+	}
+
+	var results = typ.NumOut()
+	var fallible = results > 0 && typ.Out(results-1) == errorType
+	if fallible {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("%v has too many results", typ)
+	}
+
+	return func(r *Runtime, args []interface{}) interface{} {
+		var params = typ.NumIn() - offset
+		if typ.IsVariadic() {
+			if len(args) < params-1 {
+				r.Raise([]byte(fmt.Sprintf("expected at least %v arguments but got %v", params-1, len(args))))
+				return nil
+			}
+		} else if len(args) != params {
+			r.Raise([]byte(fmt.Sprintf("expected %v arguments but got %v", params, len(args))))
+			return nil
+		}
+
+		var in = make([]reflect.Value, 0, len(args)+offset)
+		if offset == 1 {
+			in = append(in, reflect.ValueOf(r))
+		}
+		for i, arg := range args {
+			var param reflect.Type
+			if typ.IsVariadic() && i+offset >= typ.NumIn()-1 {
+				param = typ.In(typ.NumIn() - 1).Elem()
+			} else {
+				param = typ.In(i + offset)
+			}
+			converted, err := Unmarshal(arg, param)
+			if err != nil {
+				r.Raise([]byte(fmt.Sprintf("argument %v: %v", i, err)))
+				return nil
+			}
+			in = append(in, converted)
+		}
+
+		var out = value.Call(in)
+		if fallible {
+			if err := out[len(out)-1]; !err.IsNil() {
+				r.Raise([]byte(err.Interface().(error).Error()))
+			}
+		}
+		if results == 0 {
+			return nil
+		}
+
+		result, err := Marshal(out[0].Interface())
+		if err != nil {
+			r.Raise([]byte(err.Error()))
+			return nil
+		}
+		return result
+	}, nil
+}
@@ -0,0 +1,131 @@
+package runtime_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/qlova/usm/target/runtime"
+)
+
+//natives registers the Go functions used by the native tests.
+func natives(t *testing.T, c *runtime.Target) {
+	t.Helper()
+
+	var functions = map[string]interface{}{
+		"repeat": strings.Repeat,
+		"join": func(separator string, parts ...string) string {
+			return strings.Join(parts, separator)
+		},
+		"write": func(r *runtime.Runtime, message []byte) {
+			r.Host.Stdout.Write(message)
+		},
+		"check": func(message string) (string, error) {
+			if message == "" {
+				return "", errors.New("empty message")
+			}
+			return message, nil
+		},
+	}
+	for name, function := range functions {
+		if _, err := c.Register(name, function); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNative(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target)
+		output  string
+	}{
+		{"typed arguments", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Send(nil, c.Call(c.Natives["repeat"], c.String("ab"), number(c, 3))))
+			})
+		}, "ababab"},
+		{"runtime parameter", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Call(c.Natives["write"], c.String("direct")))
+			})
+		}, "direct"},
+		{"error result", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Send(nil, c.Call(c.Natives["check"], c.String("ok;"))))
+				c.Discard(c.Call(c.Natives["check"], c.String("")))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "ok;empty message"},
+		{"variadic", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Send(nil, c.Call(c.Natives["join"], c.String(","), c.String("a"), c.String("b"), c.String("c"))))
+				c.Discard(c.Send(nil, c.Call(c.Natives["join"], c.String(","))))
+			})
+		}, "a,b,c"},
+		{"native value", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Send(nil, c.Call(0, c.Native([]byte("repeat")), c.String("ab"), number(c, 2))))
+			})
+		}, "abab"},
+		{"arity", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Call(c.Natives["repeat"], c.String("ab")))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "expected 2 arguments but got 1"},
+		{"variadic arity", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Call(c.Natives["join"]))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "expected at least 1 arguments but got 0"},
+		{"argument conversion", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Call(c.Natives["repeat"], number(c, 1), number(c, 2)))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "argument 0: runtime.Unmarshal: cannot convert number to string"},
+		{"unknown native", func(c *runtime.Target) {
+			c.Main(func() {
+				c.Discard(c.Native([]byte("missing")))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "undefined native: missing"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, func(c *runtime.Target) {
+				natives(t, c)
+				test.program(c)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var tests = []struct {
+		name     string
+		native   string
+		function interface{}
+	}{
+		{"not a function", "string", "repeat"},
+		{"too many results", "pair", func() (int, int) { return 0, 0 }},
+		{"already registered", "repeat", strings.Repeat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var c runtime.Target
+			natives(t, &c)
+			if _, err := c.Register(test.native, test.function); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
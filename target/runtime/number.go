@@ -0,0 +1,150 @@
+package runtime
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//arithmetic returns a Value that applies the operation to the Numbers a and b.
+func arithmetic(a, b usm.Number, operation func(r *Runtime, x, y *big.Int) interface{}) Value {
+	var A = a.(Value)
+	var B = b.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return operation(r, A.Evaluate(r).(*big.Int), B.Evaluate(r).(*big.Int))
+	})
+}
+
+//bits returns the number of bits of the larger of x and y.
+func bits(x, y *big.Int) int64 {
+	if x.BitLen() > y.BitLen() {
+		return int64(x.BitLen())
+	}
+	return int64(y.BitLen())
+}
+
+//Add returns the sum of a and b.
+func (t *Target) Add(a usm.Number, b usm.Number) usm.Number {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		r.AllocateNumber(bits(x, y) + 1)
+		return new(big.Int).Add(x, y)
+	})
+}
+
+//Mul returns the product of a and b.
+func (t *Target) Mul(a usm.Number, b usm.Number) usm.Number {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		r.AllocateNumber(int64(x.BitLen()) + int64(y.BitLen()))
+		return new(big.Int).Mul(x, y)
+	})
+}
+
+//Sub returns the difference between a and b.
+func (t *Target) Sub(a usm.Number, b usm.Number) usm.Number {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		r.AllocateNumber(bits(x, y) + 1)
+		return new(big.Int).Sub(x, y)
+	})
+}
+
+//Div returns the quotient of a and b, truncated towards zero.
+//Division by zero throws an error and returns zero.
+func (t *Target) Div(a usm.Number, b usm.Number) usm.Number {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		if y.Sign() == 0 {
+			r.Raise([]byte("division by zero"))
+			return new(big.Int)
+		}
+		return new(big.Int).Quo(x, y)
+	})
+}
+
+//Mod returns the modulos of a and b. Must mimic Go % operator.
+//Modulo by zero throws an error and returns zero.
+func (t *Target) Mod(a usm.Number, b usm.Number) usm.Number {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		if y.Sign() == 0 {
+			r.Raise([]byte("division by zero"))
+			return new(big.Int)
+		}
+		return new(big.Int).Rem(x, y)
+	})
+}
+
+//Pow returns a to the power of b.
+//Negative powers are truncated towards zero, as with Div.
+func (t *Target) Pow(a usm.Number, b usm.Number) usm.Number {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		if y.Sign() >= 0 {
+			//The result has about x.BitLen() * y bits, which is counted before the power is computed.
+			if x.CmpAbs(big.NewInt(1)) > 0 {
+				var size = new(big.Int).Mul(big.NewInt(int64(x.BitLen())), y)
+				if !size.IsInt64() {
+					size.SetInt64(math.MaxInt64)
+				}
+				r.AllocateNumber(size.Int64())
+			}
+			return new(big.Int).Exp(x, y, nil)
+		}
+
+		switch {
+		case x.Sign() == 0:
+			r.Raise([]byte("division by zero"))
+			return new(big.Int)
+		case x.CmpAbs(big.NewInt(1)) != 0:
+			return new(big.Int)
+		case x.Sign() < 0 && y.Bit(0) == 1:
+			return big.NewInt(-1)
+		default:
+			return big.NewInt(1)
+		}
+	})
+}
+
+//Less returns 1 if a is smaller than b, otherwise 0.
+func (t *Target) Less(a usm.Number, b usm.Number) usm.Bit {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		return x.Cmp(y) < 0
+	})
+}
+
+//More returns 1 if a is larger than b, otherwise 0.
+func (t *Target) More(a usm.Number, b usm.Number) usm.Bit {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		return x.Cmp(y) > 0
+	})
+}
+
+//Same returns 1 if a is equal to b, otherwise 0.
+func (t *Target) Same(a usm.Number, b usm.Number) usm.Bit {
+	return arithmetic(a, b, func(r *Runtime, x, y *big.Int) interface{} {
+		return x.Cmp(y) == 0
+	})
+}
+
+//And returns a && b, Numbers are true if they are not zero, see Truth.
+func (t *Target) And(a usm.Bit, b usm.Bit) usm.Bit {
+	var A = a.(Value)
+	var B = b.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return Truth(A.Evaluate(r)) && Truth(B.Evaluate(r))
+	})
+}
+
+//Or returns a || b, Numbers are true if they are not zero, see Truth.
+func (t *Target) Or(a usm.Bit, b usm.Bit) usm.Bit {
+	var A = a.(Value)
+	var B = b.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return Truth(A.Evaluate(r)) || Truth(B.Evaluate(r))
+	})
+}
+
+//Not returns !Bit, Numbers are true if they are not zero, see Truth.
+func (t *Target) Not(bit usm.Bit) usm.Bit {
+	var B = bit.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return !Truth(B.Evaluate(r))
+	})
+}
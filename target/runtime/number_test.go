@@ -0,0 +1,101 @@
+package runtime_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestArithmetic(t *testing.T) {
+	var large, _ = new(big.Int).SetString("1267650600228229401496703205376", 10)
+
+	var tests = []struct {
+		name       string
+		expression func(c *runtime.Target) usm.Value
+		expected   *big.Int
+
+		//thrown is the error that the expression throws, if any.
+		thrown string
+	}{
+		{"div", func(c *runtime.Target) usm.Value { return c.Div(number(c, 7), number(c, 2)) }, big.NewInt(3), ""},
+		{"div truncates negative quotients", func(c *runtime.Target) usm.Value { return c.Div(number(c, -7), number(c, 2)) }, big.NewInt(-3), ""},
+		{"div by a negative", func(c *runtime.Target) usm.Value { return c.Div(number(c, 7), number(c, -2)) }, big.NewInt(-3), ""},
+		{"div by zero", func(c *runtime.Target) usm.Value { return c.Div(number(c, 7), number(c, 0)) }, big.NewInt(0), "division by zero"},
+		{"mod", func(c *runtime.Target) usm.Value { return c.Mod(number(c, 7), number(c, 3)) }, big.NewInt(1), ""},
+		{"mod takes the sign of the dividend", func(c *runtime.Target) usm.Value { return c.Mod(number(c, -7), number(c, 3)) }, big.NewInt(-1), ""},
+		{"mod by a negative", func(c *runtime.Target) usm.Value { return c.Mod(number(c, 7), number(c, -3)) }, big.NewInt(1), ""},
+		{"mod by zero", func(c *runtime.Target) usm.Value { return c.Mod(number(c, 7), number(c, 0)) }, big.NewInt(0), "division by zero"},
+		{"pow", func(c *runtime.Target) usm.Value { return c.Pow(number(c, 2), number(c, 100)) }, large, ""},
+		{"pow of a negative", func(c *runtime.Target) usm.Value { return c.Pow(number(c, -3), number(c, 3)) }, big.NewInt(-27), ""},
+		{"negative pow truncates", func(c *runtime.Target) usm.Value { return c.Pow(number(c, 2), number(c, -1)) }, big.NewInt(0), ""},
+		{"negative pow of one", func(c *runtime.Target) usm.Value { return c.Pow(number(c, 1), number(c, -3)) }, big.NewInt(1), ""},
+		{"odd negative pow of minus one", func(c *runtime.Target) usm.Value { return c.Pow(number(c, -1), number(c, -3)) }, big.NewInt(-1), ""},
+		{"even negative pow of minus one", func(c *runtime.Target) usm.Value { return c.Pow(number(c, -1), number(c, -2)) }, big.NewInt(1), ""},
+		{"negative pow of zero", func(c *runtime.Target) usm.Value { return c.Pow(number(c, 0), number(c, -1)) }, big.NewInt(0), "division by zero"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, runtime.Limits{}, func(c *runtime.Target) {
+				c.Main(func() {
+					var result = c.Var(test.expression(c))
+					c.If(c.Same(c.Get(result), c.Number(test.expected)), func() {
+						c.Discard(c.Send(nil, c.String("=")))
+					}, nil, func() {
+						c.Discard(c.Send(nil, c.String("!=")))
+					})
+					c.If(c.More(c.Errors(), number(c, 0)), func() {
+						c.Discard(c.Send(nil, c.Catch()))
+					}, nil, nil)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != "="+test.thrown {
+				t.Fatalf("expected %v and %q to be thrown, got %q", test.expected, test.thrown, output)
+			}
+		})
+	}
+}
+
+func TestLogic(t *testing.T) {
+	var tests = []struct {
+		name     string
+		bit      func(c *runtime.Target) usm.Bit
+		expected bool
+	}{
+		{"and", func(c *runtime.Target) usm.Bit { return c.And(c.Bit(true), c.Bit(false)) }, false},
+		{"or", func(c *runtime.Target) usm.Bit { return c.Or(c.Bit(false), c.Bit(true)) }, true},
+		{"not", func(c *runtime.Target) usm.Bit { return c.Not(c.Bit(false)) }, true},
+		{"and of numbers", func(c *runtime.Target) usm.Bit { return c.And(number(c, 2), number(c, -1)) }, true},
+		{"and of a zero", func(c *runtime.Target) usm.Bit { return c.And(number(c, 2), number(c, 0)) }, false},
+		{"or of numbers", func(c *runtime.Target) usm.Bit { return c.Or(number(c, 0), number(c, 5)) }, true},
+		{"or of zeros", func(c *runtime.Target) usm.Bit { return c.Or(number(c, 0), c.Bit(false)) }, false},
+		{"not of a number", func(c *runtime.Target) usm.Bit { return c.Not(number(c, 3)) }, false},
+		{"not of zero", func(c *runtime.Target) usm.Bit { return c.Not(number(c, 0)) }, true},
+		{"comparisons", func(c *runtime.Target) usm.Bit {
+			return c.And(c.Less(number(c, -1), number(c, 1)), c.More(number(c, 1), number(c, -1)))
+		}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, runtime.Limits{}, func(c *runtime.Target) {
+				c.Main(func() {
+					c.If(test.bit(c), func() {
+						c.Discard(c.Send(nil, c.String("true")))
+					}, nil, func() {
+						c.Discard(c.Send(nil, c.String("false")))
+					})
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != map[bool]string{true: "true", false: "false"}[test.expected] {
+				t.Fatalf("expected %v, got %v", test.expected, output)
+			}
+		})
+	}
+}
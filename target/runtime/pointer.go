@@ -0,0 +1,39 @@
+package runtime
+
+import "github.com/qlova/usm"
+
+//Pointer is a runtime usm.Pointer, a shared cell that holds a value.
+//Copies of a Pointer refer to the same cell, so a Change through one is seen through all of them.
+type Pointer struct {
+	Value interface{}
+}
+
+//Pointer retuns a pointer to a new cell holding the provided value.
+//The cell holds a copy of the value, it does not alias the register the value came from.
+func (t *Target) Pointer(value usm.Value) usm.Pointer {
+	var v = value.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var value = v.Evaluate(r)
+		return r.effect(func() interface{} {
+			r.Allocate(ElementSize)
+			return &Pointer{Value: value}
+		})
+	})
+}
+
+//Follow returns the value that the pointer is pointing at.
+func (t *Target) Follow(pointer usm.Pointer) usm.Value {
+	var p = pointer.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return p.Evaluate(r).(*Pointer).Value
+	})
+}
+
+//Change changes the pointer value to the provided Value.
+func (t *Target) Change(pointer usm.Pointer, value usm.Value) {
+	var p = pointer.(Value)
+	var v = value.(Value)
+	t.Write(func(r *Runtime) {
+		p.Evaluate(r).(*Pointer).Value = v.Evaluate(r)
+	})
+}
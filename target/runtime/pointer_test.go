@@ -0,0 +1,107 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestPointers(t *testing.T) {
+	var tests = []struct {
+		name, output string
+		program      func(c *runtime.Target)
+	}{
+		{"copies of a pointer", "22", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var p = c.Var(c.Pointer(number(c, 1)))
+				var q = c.Var(c.Get(p))
+				c.Change(c.Get(q), number(c, 2))
+				c.JumpTo(show, c.Follow(c.Get(p)))
+				c.JumpTo(show, c.Follow(c.Get(q)))
+			})
+		}},
+		{"pointers in arrays and tables", "34", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var p = c.Var(c.Pointer(number(c, 1)))
+				var array = c.Var(c.Array(c.Get(p)))
+				var table = c.Var(c.Table(map[usm.Value]usm.Value{c.String("p"): c.Get(p)}))
+				c.Change(c.Index(c.Get(array), number(c, 0)), number(c, 3))
+				c.JumpTo(show, c.Follow(c.Lookup(c.Get(table), c.String("p"))))
+				c.Change(c.Lookup(c.Get(table), c.String("p")), number(c, 4))
+				c.JumpTo(show, c.Follow(c.Get(p)))
+			})
+		}},
+		{"pointers hold copies", "15", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var x = c.Var(number(c, 1))
+				var p = c.Var(c.Pointer(c.Get(x)))
+				c.Change(c.Get(p), number(c, 5))
+				c.JumpTo(show, c.Get(x))
+				c.JumpTo(show, c.Follow(c.Get(p)))
+			})
+		}},
+		{"shared values", "9", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var p = c.Var(c.Pointer(c.Array(number(c, 0))))
+				var q = c.Var(c.Get(p))
+				c.Mutate(c.Follow(c.Get(q)), number(c, 0), number(c, 9))
+				c.JumpTo(show, c.Index(c.Follow(c.Get(p)), number(c, 0)))
+			})
+		}},
+		{"function arguments", "3", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var increment = c.Define(1, func() {
+					c.Change(c.Get(usm.Arg(0)), c.Add(c.Follow(c.Get(usm.Arg(0))), number(c, 1)))
+				})
+				var p = c.Var(c.Pointer(number(c, 0)))
+				c.JumpTo(increment, c.Get(p))
+				c.JumpTo(increment, c.Get(p))
+				c.JumpTo(increment, c.Get(p))
+				c.JumpTo(show, c.Follow(c.Get(p)))
+			})
+		}},
+		{"reassigned arguments", "17", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				//replace points its argument at a new cell, which the caller does not see.
+				var replace = c.Define(1, func() {
+					c.Set(usm.Arg(0), c.Pointer(number(c, 0)))
+					c.Change(c.Get(usm.Arg(0)), number(c, 7))
+					c.Return(c.Get(usm.Arg(0)))
+				})
+				var p = c.Var(c.Pointer(number(c, 1)))
+				var q = c.Var(c.Call(replace, c.Get(p)))
+				c.JumpTo(show, c.Follow(c.Get(p)))
+				c.JumpTo(show, c.Follow(c.Get(q)))
+			})
+		}},
+		{"returned pointers", "8", func(c *runtime.Target) {
+			c.Main(func() {
+				var show = show(c)
+				var cell = c.Var(c.Pointer(number(c, 0)))
+				var get = c.Define(1, func() {
+					c.Return(c.Get(usm.Arg(0)))
+				})
+				c.Change(c.Call(get, c.Get(cell)), number(c, 8))
+				c.JumpTo(show, c.Follow(c.Get(cell)))
+			})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := run(t, runtime.Limits{}, test.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package runtime
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/qlova/usm"
+)
+
+//WriteProfile writes the trace as a gzipped pprof profile, so that it can be analyzed with `go tool pprof`.
+//Each function is named after its label, and each line of a function is one of its statements, counting from 1.
+//Samples hold the number of statements executed and the time spent at each call stack.
+func (t *Tracer) WriteProfile(w io.Writer) error {
+	var p profile
+	p.strings = map[string]int64{"": 0}
+	p.table = []string{""}
+	p.functions = make(map[usm.Label]uint64)
+	p.locations = make(map[Location]uint64)
+
+	var message protobuf
+	message.message(1, p.valueType("statements", "count"))
+	message.message(1, p.valueType("time", "nanoseconds"))
+
+	var keys = make([]string, 0, len(t.samples))
+	for key := range t.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var s = t.samples[key]
+
+		var ids = make([]uint64, len(s.stack))
+		for i, location := range s.stack {
+			ids[len(ids)-1-i] = p.location(location)
+		}
+
+		var sample protobuf
+		sample.packed(1, ids)
+		sample.packed(2, []uint64{uint64(s.statements), uint64(s.time.Nanoseconds())})
+		message.message(2, sample)
+	}
+
+	message = append(message, p.body...)
+	for _, s := range p.table {
+		message.bytes(6, []byte(s))
+	}
+	message.varint(9, uint64(t.started.UnixNano()))
+	message.varint(10, uint64(t.Duration.Nanoseconds()))
+	message.message(11, p.valueType("time", "nanoseconds"))
+
+	var writer = gzip.NewWriter(w)
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+//profile builds the string, function and location tables of a pprof profile.
+type profile struct {
+	strings map[string]int64
+	table   []string
+
+	functions map[usm.Label]uint64
+	locations map[Location]uint64
+
+	//body holds the encoded locations and functions.
+	body protobuf
+}
+
+func (p *profile) string(s string) int64 {
+	if index, ok := p.strings[s]; ok {
+		return index
+	}
+	p.strings[s] = int64(len(p.table))
+	p.table = append(p.table, s)
+	return p.strings[s]
+}
+
+func (p *profile) valueType(typ, unit string) protobuf {
+	var message protobuf
+	message.varint(1, uint64(p.string(typ)))
+	message.varint(2, uint64(p.string(unit)))
+	return message
+}
+
+func (p *profile) function(label usm.Label) uint64 {
+	if id, ok := p.functions[label]; ok {
+		return id
+	}
+	var id = uint64(len(p.functions) + 1)
+	p.functions[label] = id
+
+	var name = "main"
+	if label != 0 {
+		name = "label " + strconv.FormatUint(uint64(label), 10)
+	}
+
+	var message protobuf
+	message.varint(1, id)
+	message.varint(2, uint64(p.string(name)))
+	message.varint(3, uint64(p.string(name)))
+	message.varint(4, uint64(p.string("usm")))
+	p.body.message(5, message)
+	return id
+}
+
+func (p *profile) location(location Location) uint64 {
+	if id, ok := p.locations[location]; ok {
+		return id
+	}
+	var id = uint64(len(p.locations) + 1)
+	p.locations[location] = id
+
+	var line protobuf
+	line.varint(1, p.function(location.Label))
+	line.varint(2, uint64(location.Statement+1))
+
+	var message protobuf
+	message.varint(1, id)
+	message.message(4, line)
+	p.body.message(4, message)
+	return id
+}
+
+//protobuf is an encoded protocol buffer message.
+type protobuf []byte
+
+func (b *protobuf) uvarint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protobuf) varint(field int, v uint64) {
+	b.uvarint(uint64(field) << 3)
+	b.uvarint(v)
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.uvarint(uint64(field)<<3 | 2)
+	b.uvarint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+func (b *protobuf) message(field int, message protobuf) {
+	b.bytes(field, message)
+}
+
+func (b *protobuf) packed(field int, values []uint64) {
+	var data protobuf
+	for _, v := range values {
+		data.uvarint(v)
+	}
+	b.bytes(field, data)
+}
@@ -0,0 +1,194 @@
+package runtime_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/qlova/usm/target/runtime"
+)
+
+//field is a field of an encoded protocol buffer message, varint fields have a value and length-delimited fields have data.
+type field struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+//fields decodes the fields of a protocol buffer message.
+func fields(t *testing.T, message []byte) []field {
+	t.Helper()
+	var result []field
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			t.Fatal("invalid key")
+		}
+		message = message[n:]
+
+		var f = field{number: int(key >> 3)}
+		value, n := binary.Uvarint(message)
+		if n <= 0 {
+			t.Fatalf("invalid value of field %v", f.number)
+		}
+		message = message[n:]
+
+		switch key & 7 {
+		case 0:
+			f.value = value
+		case 2:
+			if value > uint64(len(message)) {
+				t.Fatalf("field %v is longer than its message", f.number)
+			}
+			f.data, message = message[:value], message[value:]
+		default:
+			t.Fatalf("unexpected wire type %v", key&7)
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+//packed decodes a packed repeated varint field.
+func packed(t *testing.T, data []byte) []uint64 {
+	t.Helper()
+	var values []uint64
+	for len(data) > 0 {
+		value, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatal("invalid packed value")
+		}
+		values, data = append(values, value), data[n:]
+	}
+	return values
+}
+
+func TestWriteProfile(t *testing.T) {
+	var c runtime.Target
+	calling(&c)
+	var tracer = runtime.NewTracer(&c.Runtime)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tracer.WriteProfile(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The fields of a Profile, see github.com/google/pprof/proto/profile.proto.
+	const (
+		sampleType  = 1
+		sample      = 2
+		location    = 4
+		function    = 5
+		stringTable = 6
+	)
+
+	var profile = fields(t, message)
+	var table []string
+	for _, f := range profile {
+		if f.number == stringTable {
+			table = append(table, string(f.data))
+		}
+	}
+	if len(table) == 0 || table[0] != "" {
+		t.Fatalf("the string table must begin with the empty string, got %q", table)
+	}
+	var lookup = func(index uint64) string {
+		if index >= uint64(len(table)) {
+			t.Fatalf("string %v is not in the table", index)
+		}
+		return table[index]
+	}
+
+	var types []string
+	var functions = make(map[uint64]string)
+	var locations = make(map[uint64]uint64)
+	for _, f := range profile {
+		switch f.number {
+		case sampleType:
+			var typ = fields(t, f.data)
+			types = append(types, lookup(typ[0].value)+"/"+lookup(typ[1].value))
+		case function:
+			var id, name uint64
+			for _, f := range fields(t, f.data) {
+				switch f.number {
+				case 1:
+					id = f.value
+				case 2:
+					name = f.value
+				}
+			}
+			functions[id] = lookup(name)
+		case location:
+			var id, fn uint64
+			for _, f := range fields(t, f.data) {
+				switch f.number {
+				case 1:
+					id = f.value
+				case 4:
+					fn = fields(t, f.data)[0].value
+				}
+			}
+			locations[id] = fn
+		}
+	}
+	if len(types) != 2 || types[0] != "statements/count" || types[1] != "time/nanoseconds" {
+		t.Fatalf("unexpected sample types %q", types)
+	}
+	if len(functions) != 2 || functions[1] != "main" || functions[2] != "label 1" {
+		t.Fatalf("unexpected functions %q", functions)
+	}
+
+	var statements, executed uint64
+	var called bool
+	for _, f := range profile {
+		if f.number != sample {
+			continue
+		}
+		var ids, values []uint64
+		for _, f := range fields(t, f.data) {
+			switch f.number {
+			case 1:
+				ids = packed(t, f.data)
+			case 2:
+				values = packed(t, f.data)
+			}
+		}
+		if len(values) != 2 {
+			t.Fatalf("expected 2 values, got %v", values)
+		}
+		statements += values[0]
+
+		for _, id := range ids {
+			fn, ok := locations[id]
+			if !ok || functions[fn] == "" {
+				t.Fatalf("sample refers to an undefined location %v", id)
+			}
+		}
+		//The leaf of a sample is first.
+		if len(ids) == 2 && functions[locations[ids[0]]] == "label 1" && functions[locations[ids[1]]] == "main" {
+			called = true
+		}
+	}
+	for _, n := range tracer.Statements {
+		executed += uint64(n)
+	}
+	if statements != executed {
+		t.Fatalf("the samples hold %v statements, expected %v", statements, executed)
+	}
+	if !called {
+		t.Fatal("expected a sample for the call from main to label 1")
+	}
+}
@@ -1,18 +1,59 @@
 package runtime
 
-import "github.com/qlova/usm"
+import (
+	"context"
+	"math/big"
 
-//Value is a runtime usm.Value
-type Value func() interface{}
+	"github.com/qlova/usm"
+)
+
+//Expression evaluates to a Go value under the given Runtime.
+type Expression func(r *Runtime) interface{}
+
+//Evaluate evaluates the Expression under the given Runtime.
+func (e *Expression) Evaluate(r *Runtime) interface{} {
+	return (*e)(r)
+}
+
+//Value is a runtime usm.Value, a pointer so that it can key the elements of a Table.
+type Value = *Expression
+
+//NewValue returns the Expression as a Value.
+func NewValue(e Expression) Value {
+	return &e
+}
 
 //Block is a runtime usm.Block
 type Block struct {
 	Function bool
 
-	Statements []func()
+	//ID numbers the block in the order that the program was built, Snapshots refer to blocks by their ID.
+	ID int
+
+	//Label is the label of a function block, 0 for Main.
+	Label usm.Label
+
+	//Arguments is the number of arguments a function block expects.
+	Arguments int
+
+	//Registers is the number of variables a function block holds.
+	Registers int
+
+	//Native is called instead of running the statements, if it is not nil.
+	Native NativeFunction
+
+	Statements []func(*Runtime)
+
+	//Lines numbers each statement within its function, see Location.
+	Lines []int
+
+	//next is the number of the statement that is being written plus one, or 0 if it has not been numbered.
+	next int
 }
 
 //RunWith runs a block with the given runtime.
+//Function blocks receive their own variables and the given arguments,
+//other blocks share the variables and arguments of the enclosing function.
 func (block Block) RunWith(r *Runtime, args ...interface{}) error {
 	if len(block.Statements) == 0 {
 		return nil
@@ -21,21 +62,55 @@ func (block Block) RunWith(r *Runtime, args ...interface{}) error {
 	r.Push()
 	defer r.Pop()
 
-	r.Args = args
-	for {
-		if r.ProgramCounter >= len(block.Statements) {
-			return nil
+	var resumed = len(r.resume) > 0
+	if resumed {
+		if r.resume[0].Block != block.ID {
+			r.Halt(ErrMismatch)
+		}
+		r.Scope, r.resume = r.resume[0], r.resume[1:]
+	} else if block.Function {
+		r.Function, r.Label = true, block.Label
+		r.Variables = make([]interface{}, block.Registers)
+		r.Args = args
+	}
+	r.Block = block.ID
+
+	for r.ProgramCounter < len(block.Statements) {
+		r.Statement = block.Lines[r.ProgramCounter]
+		if resumed {
+			//The statement was counted before the snapshot was taken,
+			//only the statement that the runtime stopped before is seen again by the debugger and tracer.
+			resumed = false
+			if len(r.resume) == 0 {
+				r.hooks()
+			}
+		} else {
+			r.effects = r.effects[:0]
+			r.step()
+			r.hooks()
 		}
-		block.Statements[r.ProgramCounter]()
+		block.Statements[r.ProgramCounter](r)
 		r.ProgramCounter++
 
-		if r.Returning {
+		if r.Returning || r.Breaking {
 			if block.Function {
 				r.Returning = false
+				r.Breaking = false
 			}
 			return nil
 		}
 	}
+	return nil
+}
+
+//hooks calls the debugger and tracer before a statement.
+func (r *Runtime) hooks() {
+	if r.Debugger != nil {
+		r.Debugger.statement(r)
+	}
+	if r.Tracer != nil {
+		r.Tracer.statement(r)
+	}
 }
 
 //Runtime is a runtime object for a runtime `u` target.
@@ -48,44 +123,179 @@ type Runtime struct {
 
 	ReturnValue interface{}
 	Returning   bool
+
+	//Breaking is set by Break and cleared by the inner-most loop.
+	Breaking bool
+
+	//Thrown is the error stack.
+	Thrown []interface{}
+
+	//Host is the environment that the runtime runs in.
+	Host Host
+
+	//Streams are the streams opened or forked by this runtime.
+	Streams []*Stream
+
+	//Limits restricts the resources that the runtime may use.
+	Limits Limits
+
+	//Executed, Depth and Allocated are measured against the Limits.
+	//Executed and Allocated only count this runtime, the Limits also count its forks.
+	Executed  int64
+	Depth     int
+	Allocated int64
+
+	//shared is the budget that this runtime shares with its forks.
+	shared *budget
+
+	//Debugger, if not nil, is able to pause the runtime before each statement.
+	Debugger *Debugger
+
+	//Tracer, if not nil, records the functions and statements that the runtime executes.
+	Tracer *Tracer
+
+	//resume holds the scopes that are yet to be re-entered after a Restore, the outer-most first.
+	resume []Scope
+
+	context context.Context
+}
+
+//Raise pushes the value onto the error stack.
+func (r *Runtime) Raise(value interface{}) {
+	r.Thrown = append(r.Thrown, value)
 }
 
-//Push pushes a new scope.
+//Check raises the error as a String if it is not nil.
+func (r *Runtime) Check(err error) {
+	if err != nil {
+		r.Raise([]byte(err.Error()))
+	}
+}
+
+//Bounds returns true if i is a valid index for a sequence of the given length.
+//Otherwise an error is raised.
+func (r *Runtime) Bounds(i *big.Int, length int) bool {
+	if !i.IsInt64() || i.Int64() < 0 || i.Int64() >= int64(length) {
+		r.Raise([]byte("index out of range"))
+		return false
+	}
+	return true
+}
+
+//Push pushes a new scope that shares the variables and arguments of the current scope.
 func (r *Runtime) Push() {
 	r.Scopes = append(r.Scopes, r.Scope)
-	r.Scope = NewScope()
-	r.Backup = r.ProgramCounter
-	r.ProgramCounter = 0
+	r.Scope = Scope{
+		Label:     r.Label,
+		Args:      r.Args,
+		Variables: r.Variables,
+	}
 }
 
 //Pop pops the last scope.
 func (r *Runtime) Pop() {
-	var backup = r.Backup
 	r.Scope = r.Scopes[len(r.Scopes)-1]
 	r.Scopes = r.Scopes[:len(r.Scopes)-1]
-	r.ProgramCounter = backup
 }
 
 //Run runs the runtime.
 func (r *Runtime) Run() error {
-	r.Scope = NewScope()
-	r.Scopes = nil
+	return r.RunContext(context.Background())
+}
+
+//Close closes the streams opened by the runtime, forked runtimes are then able to finish.
+func (r *Runtime) Close() error {
+	for _, stream := range r.Streams {
+		stream.Close()
+	}
+	r.Streams = nil
+	return nil
+}
 
-	return r.Entrypoint.RunWith(r)
+//Loop runs the body of a loop once, each iteration counts as a statement.
+//Returns false if the loop should stop, either from a Break or a Return.
+func (r *Runtime) Loop(body Block) bool {
+	if !r.resuming(body) {
+		r.step()
+	}
+	body.RunWith(r)
+	if r.Breaking {
+		r.Breaking = false
+		return false
+	}
+	return !r.Returning
+}
+
+//Jump runs the function at the given label with the arguments.
+//If the label is 0, then the first argument is treated as a label bind and subsequent arguments are passed.
+//An error is thrown if there is no function to jump to, or if it expects a different number of arguments.
+func (r *Runtime) Jump(label usm.Label, args ...interface{}) {
+	if label == 0 {
+		var function Function
+		var ok bool
+		if len(args) > 0 {
+			function, ok = args[0].(Function)
+		}
+		if !ok {
+			r.Raise([]byte("not a function"))
+			return
+		}
+		label, args = function.Label, args[1:]
+	}
+	if label < 1 || int(label) > len(r.Blocks) {
+		r.Raise([]byte("undefined function"))
+		return
+	}
+	if block := r.Blocks[label-1]; block.Native == nil && len(args) != block.Arguments {
+		r.Raise([]byte("wrong number of arguments"))
+		return
+	}
+
+	r.Depth++
+	defer func() { r.Depth-- }()
+	if r.Depth > r.Limits.depth() {
+		r.Halt(&LimitError{DepthLimit, int64(r.Limits.depth())})
+	}
+
+	if r.Tracer != nil {
+		r.Tracer.enter(label)
+		defer r.Tracer.exit()
+	}
+
+	if native := r.Blocks[label-1].Native; native != nil {
+		r.ReturnValue = native(r, args)
+		return
+	}
+	r.Blocks[label-1].RunWith(r, args...)
+}
+
+//Function is a runtime usm.Function, a label bound with Bind.
+//Functions are comparable, so they can be compared and used in tables and arrays like any other value.
+type Function struct {
+	Label usm.Label
 }
 
 //Scope is the current scope.
 type Scope struct {
-	Backup         int
+	//Function is true if the scope is the outer-most scope of a function call.
+	Function bool
+
+	//Label is the label of the function that the scope belongs to, 0 for Main.
+	Label usm.Label
+
 	ProgramCounter int
 
+	//Statement is the number of the current statement within its function, see Location.
+	Statement int
+
+	//Block is the ID of the block that the scope is running.
+	Block int
+
+	//Args and Variables are indexed by register, see usm.Arg.
 	Args      []interface{}
-	Variables map[usm.Register]interface{}
-}
+	Variables []interface{}
 
-//NewScope returns a new scope.
-func NewScope() Scope {
-	return Scope{
-		Variables: make(map[usm.Register]interface{}),
-	}
+	//effects are the results of the expressions with side effects that the current statement has evaluated,
+	//replay holds the effects that are yet to be replayed after a Restore.
+	effects, replay []interface{}
 }
@@ -0,0 +1,42 @@
+package runtime_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//run builds the program with a runtime.Target under the limits, runs it and returns its output.
+func run(t *testing.T, limits runtime.Limits, program func(c *runtime.Target)) (string, error) {
+	t.Helper()
+
+	var c runtime.Target
+	var output bytes.Buffer
+	c.Host.Stdout = &output
+	c.Limits = limits
+	program(&c)
+	var err = c.Run()
+	return output.String(), err
+}
+
+//number returns the Number of the int64.
+func number(c usm.Target, i int64) usm.Number {
+	return c.Number(big.NewInt(i))
+}
+
+//show defines a function that writes its argument, a Number that is not negative, in decimal.
+func show(c *runtime.Target) usm.Label {
+	var show = c.NextLabel()
+	return c.Define(1, func() {
+		var n = c.Get(usm.Arg(0))
+		c.If(c.More(n, number(c, 9)), func() {
+			c.JumpTo(show, c.Div(n, number(c, 10)))
+		}, nil, nil)
+		var digit = c.Var(c.Create(number(c, 1)))
+		c.Modify(c.Get(digit), number(c, 0), c.Add(number(c, '0'), c.Mod(n, number(c, 10))))
+		c.Discard(c.Send(nil, c.Get(digit)))
+	})
+}
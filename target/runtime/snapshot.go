@@ -0,0 +1,418 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/qlova/usm"
+)
+
+//SnapshotVersion is the version of the encoding written by Snapshot.
+const SnapshotVersion = 1
+
+//snapshotMagic begins every snapshot.
+const snapshotMagic = "usmsnap"
+
+//ErrMismatch is returned when a runtime resumes from a snapshot of a different program.
+var ErrMismatch = errors.New("runtime: snapshot does not match the program")
+
+//Snapshot encodes the state of a stopped runtime, so that it can be resumed by Restore in a fresh process.
+//The runtime must be stopped by a Debugger. The scopes, variables, arguments, error stack and every value
+//reachable from them are encoded, values that are shared by the runtime remain shared after a Restore.
+//Streams cannot be encoded and the Host is not part of the snapshot.
+func (r *Runtime) Snapshot() ([]byte, error) {
+	if len(r.Scopes) == 0 {
+		return nil, errors.New("runtime.Snapshot: the runtime is not running")
+	}
+
+	var e = encoder{objects: make(map[interface{}]uint64)}
+	e.WriteString(snapshotMagic)
+	e.uint(SnapshotVersion)
+
+	e.int(r.Executed)
+	e.int(r.Allocated)
+	e.values(r.Thrown)
+
+	var scopes = append(append([]Scope{}, r.Scopes[1:]...), r.Scope)
+	e.uint(uint64(len(scopes)))
+	for _, scope := range scopes {
+		e.bool(scope.Function)
+		e.uint(uint64(scope.Label))
+		e.uint(uint64(scope.Block))
+		e.uint(uint64(scope.ProgramCounter))
+		e.value(scope.Args)
+		e.value(scope.Variables)
+		e.values(scope.effects)
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.Bytes(), nil
+}
+
+//Restore restores the state encoded by Snapshot, the next Run resumes the runtime from where the snapshot was taken.
+//The runtime must have been built by the same program that the snapshot was taken from.
+func (r *Runtime) Restore(snapshot []byte) error {
+	var d = decoder{Reader: bytes.NewReader(snapshot)}
+
+	var magic = make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(d, magic); err != nil || string(magic) != snapshotMagic {
+		return errors.New("runtime.Restore: not a snapshot")
+	}
+	if version := d.uint(); version != SnapshotVersion {
+		return fmt.Errorf("runtime.Restore: unsupported snapshot version %v", version)
+	}
+
+	var executed, allocated = d.int(), d.int()
+	var thrown = d.values()
+
+	var scopes = make([]Scope, d.length())
+	for i := range scopes {
+		var scope = &scopes[i]
+		scope.Function = d.bool()
+		scope.Label = usm.Label(d.uint())
+		scope.Block = int(d.uint())
+		scope.ProgramCounter = int(d.uint())
+		scope.Args = d.frame()
+		scope.Variables = d.frame()
+		scope.effects = d.values()
+		scope.replay = append([]interface{}{}, scope.effects...)
+	}
+
+	if d.err != nil {
+		return fmt.Errorf("runtime.Restore: %w", d.err)
+	}
+	if d.Len() > 0 {
+		return errors.New("runtime.Restore: unexpected data after snapshot")
+	}
+
+	if len(scopes) == 0 || r.Entrypoint == nil || scopes[0].Block != r.Entrypoint.ID {
+		return ErrMismatch
+	}
+	for _, scope := range scopes[1:] {
+		if scope.Function && (scope.Label < 1 || int(scope.Label) > len(r.Blocks) || r.Blocks[scope.Label-1].ID != scope.Block) {
+			return ErrMismatch
+		}
+	}
+
+	r.Executed, r.Allocated = executed, allocated
+	r.Thrown = thrown
+	r.ReturnValue, r.Returning, r.Breaking = nil, false, false
+	r.resume = scopes
+	return nil
+}
+
+//effect evaluates an expression with side effects and records the result,
+//so that the runtime can replay it when it resumes the statement after a Restore.
+func (r *Runtime) effect(f func() interface{}) interface{} {
+	if len(r.replay) > 0 {
+		var value = r.replay[0]
+		r.replay = r.replay[1:]
+		return value
+	}
+	var value = f()
+	r.effects = append(r.effects, value)
+	return value
+}
+
+//resuming returns true if the runtime is resuming into the block after a Restore.
+//The block is then entered directly, so the effects of the statement that contains it are not replayed.
+func (r *Runtime) resuming(block Block) bool {
+	if len(r.resume) == 0 || r.resume[0].Block != block.ID {
+		return false
+	}
+	r.replay = nil
+	return true
+}
+
+//These tags identify the kind of each encoded value.
+const (
+	tagNil byte = iota
+	tagFalse
+	tagTrue
+	tagNumber
+	tagFunction
+	tagString
+	tagArray
+	tagTable
+	tagPointer
+	tagFrame
+	tagReference
+)
+
+//encoder encodes values, shared values are encoded once and then referenced by the order they were encoded in.
+type encoder struct {
+	bytes.Buffer
+
+	objects map[interface{}]uint64
+	err     error
+}
+
+//slice identifies a shared slice by its first element and length.
+type slice struct {
+	first  interface{}
+	length int
+}
+
+func (e *encoder) uint(v uint64) {
+	var buffer [binary.MaxVarintLen64]byte
+	e.Write(buffer[:binary.PutUvarint(buffer[:], v)])
+}
+
+func (e *encoder) int(v int64) {
+	var buffer [binary.MaxVarintLen64]byte
+	e.Write(buffer[:binary.PutVarint(buffer[:], v)])
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.WriteByte(1)
+	} else {
+		e.WriteByte(0)
+	}
+}
+
+func (e *encoder) values(values []interface{}) {
+	e.uint(uint64(len(values)))
+	for _, value := range values {
+		e.value(value)
+	}
+}
+
+//shared returns true if the object has already been encoded, otherwise it is assigned the next reference.
+func (e *encoder) shared(key interface{}) bool {
+	if reference, ok := e.objects[key]; ok {
+		e.WriteByte(tagReference)
+		e.uint(reference)
+		return true
+	}
+	e.objects[key] = uint64(len(e.objects))
+	return false
+}
+
+func (e *encoder) value(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		e.WriteByte(tagNil)
+	case bool:
+		if v {
+			e.WriteByte(tagTrue)
+		} else {
+			e.WriteByte(tagFalse)
+		}
+	case *big.Int:
+		e.WriteByte(tagNumber)
+		e.int(int64(v.Sign()))
+		var data = v.Bytes()
+		e.uint(uint64(len(data)))
+		e.Write(data)
+	case Function:
+		e.WriteByte(tagFunction)
+		e.uint(uint64(v.Label))
+	case []byte:
+		if len(v) > 0 && e.shared(slice{&v[0], len(v)}) {
+			return
+		}
+		e.WriteByte(tagString)
+		e.uint(uint64(len(v)))
+		e.Write(v)
+	case []interface{}:
+		if len(v) > 0 && e.shared(slice{&v[0], len(v)}) {
+			return
+		}
+		e.WriteByte(tagFrame)
+		e.values(v)
+	case *Array:
+		if e.shared(v) {
+			return
+		}
+		e.WriteByte(tagArray)
+		e.values(v.Values)
+	case *Table:
+		if e.shared(v) {
+			return
+		}
+		e.WriteByte(tagTable)
+		e.uint(uint64(len(v.Values)))
+		for _, key := range sortedKeys(v.Values) {
+			e.uint(uint64(len(key)))
+			e.WriteString(key)
+			e.value(v.Values[key])
+		}
+	case *Pointer:
+		if e.shared(v) {
+			return
+		}
+		e.WriteByte(tagPointer)
+		e.value(v.Value)
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("runtime.Snapshot: cannot encode a %v", Kind(value))
+		}
+		e.WriteByte(tagNil)
+	}
+}
+
+//sortedKeys returns the keys of the table values in order, so that tables are encoded deterministically.
+func sortedKeys(values map[string]interface{}) []string {
+	var keys = make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//decoder decodes the values written by an encoder.
+type decoder struct {
+	*bytes.Reader
+
+	objects []interface{}
+	err     error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) uint() uint64 {
+	v, err := binary.ReadUvarint(d)
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return v
+}
+
+func (d *decoder) int() int64 {
+	v, err := binary.ReadVarint(d)
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return v
+}
+
+func (d *decoder) bool() bool {
+	b, err := d.ReadByte()
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return b != 0
+}
+
+//length reads a length, which cannot be longer than the remaining data.
+func (d *decoder) length() int {
+	var n = d.uint()
+	if n > uint64(d.Len()) {
+		d.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	var data = make([]byte, d.length())
+	if _, err := io.ReadFull(d, data); err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return data
+}
+
+func (d *decoder) values() []interface{} {
+	var values = make([]interface{}, d.length())
+	d.fill(values)
+	return values
+}
+
+func (d *decoder) fill(values []interface{}) {
+	for i := range values {
+		if d.err != nil {
+			return
+		}
+		values[i] = d.value()
+	}
+}
+
+//frame decodes the arguments or variables of a scope.
+func (d *decoder) frame() []interface{} {
+	var frame, ok = d.value().([]interface{})
+	if !ok && d.err == nil {
+		d.fail(errors.New("invalid frame"))
+	}
+	return frame
+}
+
+func (d *decoder) value() interface{} {
+	tag, err := d.ReadByte()
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+		return nil
+	}
+
+	switch tag {
+	case tagNil:
+		return nil
+	case tagFalse:
+		return false
+	case tagTrue:
+		return true
+	case tagNumber:
+		var sign = d.int()
+		var number = new(big.Int).SetBytes(d.bytes())
+		if sign < 0 {
+			number.Neg(number)
+		}
+		return number
+	case tagFunction:
+		return Function{Label: usm.Label(d.uint())}
+	case tagString:
+		var data = d.bytes()
+		if len(data) > 0 {
+			d.objects = append(d.objects, data)
+		}
+		return data
+	case tagFrame:
+		var frame = make([]interface{}, d.length())
+		if len(frame) > 0 {
+			d.objects = append(d.objects, frame)
+		}
+		d.fill(frame)
+		return frame
+	case tagArray:
+		var array = &Array{Values: make([]interface{}, d.length())}
+		d.objects = append(d.objects, array)
+		d.fill(array.Values)
+		return array
+	case tagTable:
+		var n = d.length()
+		var table = &Table{Values: make(map[string]interface{}, n)}
+		d.objects = append(d.objects, table)
+		for i := 0; i < n && d.err == nil; i++ {
+			var key = string(d.bytes())
+			table.Values[key] = d.value()
+		}
+		return table
+	case tagPointer:
+		var pointer = new(Pointer)
+		d.objects = append(d.objects, pointer)
+		pointer.Value = d.value()
+		return pointer
+	case tagReference:
+		var reference = d.uint()
+		if reference >= uint64(len(d.objects)) {
+			d.fail(fmt.Errorf("invalid reference %v", reference))
+			return nil
+		}
+		return d.objects[reference]
+	default:
+		d.fail(fmt.Errorf("invalid tag %v", tag))
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+//resume runs the program until it has reached the breakpoint the given number of times and takes a snapshot,
+//then restores the snapshot into a freshly built copy of the program and runs it to the end.
+//The output of both runs together must be the output of running the program without stopping.
+func resume(t *testing.T, program func(c *runtime.Target), breakpoint runtime.Location, hits int) {
+	t.Helper()
+
+	var reference runtime.Target
+	var expected bytes.Buffer
+	reference.Host.Stdout = &expected
+	program(&reference)
+	if err := reference.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stopped runtime.Target
+	var before bytes.Buffer
+	stopped.Host.Stdout = &before
+	program(&stopped)
+	var debugger = runtime.NewDebugger(&stopped.Runtime)
+	debugger.Break(breakpoint.Label, breakpoint.Statement)
+
+	var stop = debugger.Start(context.Background(), false)
+	for i := 1; ; i++ {
+		if stop.Reason != runtime.Breakpoint {
+			t.Fatalf("expected breakpoint %v, got %v", i, stop.Reason)
+		}
+		if i == hits {
+			break
+		}
+		var err error
+		if stop, err = debugger.Continue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := stopped.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := debugger.Terminate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var restored runtime.Target
+	var after bytes.Buffer
+	restored.Host.Stdout = &after
+	program(&restored)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := before.String() + after.String(); output != expected.String() {
+		t.Fatalf("expected %q, got %q then %q", expected.String(), before.String(), after.String())
+	}
+	if restored.Executed != reference.Executed {
+		t.Fatalf("expected %v statements to be executed, got %v", reference.Executed, restored.Executed)
+	}
+	if fmt.Sprint(restored.Thrown) != fmt.Sprint(reference.Thrown) {
+		t.Fatalf("expected the error stack %v, got %v", reference.Thrown, restored.Thrown)
+	}
+}
+
+//loop writes a running sum of the numbers from 1 to 10 and throws each number,
+//the statements inside of its Range are 3, 4, 5 and 6.
+func loop(c *runtime.Target) {
+	c.Main(func() {
+		var show = show(c)
+		var sum = c.Var(number(c, 0))
+		var numbers = c.Var(c.Array())
+		c.Range(number(c, 1), -1, number(c, 10), number(c, 1), func(i usm.Number) {
+			c.Set(sum, c.Add(c.Get(sum), i))
+			c.Set(numbers, c.Append(c.Get(numbers), c.Get(sum)))
+			c.Discard(c.Send(nil, c.String(" ")))
+			c.Throw(i)
+		})
+		c.Each(c.Get(numbers), func(i usm.Number, v usm.Value) {
+			c.JumpTo(show, v)
+			c.Discard(c.Send(nil, c.String(",")))
+		})
+	})
+}
+
+//calls writes the factorial of 8, followed by the arguments of each call in the order that they were made.
+//Label 2 is the factorial, it appends its argument to an array shared by every call in statement 0,
+//returns from its base case in statement 2 and makes its recursive call in statement 3.
+func calls(c *runtime.Target) {
+	c.Main(func() {
+		var show, factorial = show(c), c.NextLabel()
+		c.Define(2, func() {
+			var n = c.Get(usm.Arg(0))
+			c.Set(usm.Arg(1), c.Append(c.Get(usm.Arg(1)), n))
+			c.If(c.Less(n, number(c, 2)), func() {
+				c.Return(number(c, 1))
+			}, nil, nil)
+			c.Return(c.Mul(n, c.Call(factorial, c.Sub(n, number(c, 1)), c.Get(usm.Arg(1)))))
+		})
+		var seen = c.Var(c.Array())
+		c.JumpTo(show, c.Call(factorial, number(c, 8), c.Get(seen)))
+		c.Discard(c.Send(nil, c.String(" ")))
+		c.JumpTo(show, c.Count(c.Get(seen)))
+		c.Discard(c.Send(nil, c.String(" ")))
+		c.Each(c.Get(seen), func(i usm.Number, v usm.Value) {
+			c.JumpTo(show, v)
+		})
+	})
+}
+
+func TestSnapshotLoop(t *testing.T) {
+	for _, statement := range []int{3, 4, 5, 6} {
+		for _, hits := range []int{1, 5, 10} {
+			resume(t, loop, runtime.Location{Label: 0, Statement: statement}, hits)
+		}
+	}
+}
+
+func TestSnapshotCall(t *testing.T) {
+	output, err := run(t, runtime.Limits{}, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "40320 8 87654321" {
+		t.Fatalf("expected %q, got %q", "40320 8 87654321", output)
+	}
+
+	resume(t, calls, runtime.Location{Label: 2, Statement: 2}, 1)
+	for _, hits := range []int{1, 4, 7} {
+		resume(t, calls, runtime.Location{Label: 2, Statement: 0}, hits)
+		resume(t, calls, runtime.Location{Label: 2, Statement: 3}, hits)
+	}
+}
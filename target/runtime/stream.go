@@ -0,0 +1,312 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/qlova/usm"
+)
+
+//Stream is a runtime usm.Stream.
+type Stream struct {
+	io.Reader
+	io.Writer
+
+	//Closers are closed when the stream is closed.
+	Closers []io.Closer
+}
+
+//Read reads from the stream.
+func (s *Stream) Read(b []byte) (int, error) {
+	if s.Reader == nil {
+		return 0, errors.New("stream is not readable")
+	}
+	return s.Reader.Read(b)
+}
+
+//Write writes to the stream.
+func (s *Stream) Write(b []byte) (int, error) {
+	if s.Writer == nil {
+		return 0, errors.New("stream is not writable")
+	}
+	return s.Writer.Write(b)
+}
+
+//Close closes the stream.
+func (s *Stream) Close() error {
+	var err error
+	for _, closer := range s.Closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Read reads stream data into the given string, returns the number of bytes read.
+//If the stream is nil, then the runtime's Stdin is read from.
+//This may throw an error.
+func (t *Target) Read(stream usm.Stream, data usm.String) usm.Value {
+	var d = data.(Value)
+	if stream == nil {
+		return NewValue(func(r *Runtime) interface{} {
+			var data = d.Evaluate(r).([]byte)
+			return r.effect(func() interface{} {
+				n, err := r.Host.stdin().Read(data)
+				r.Check(err)
+				return big.NewInt(int64(n))
+			})
+		})
+	}
+	var s = stream.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var stream, data = s.Evaluate(r).(*Stream), d.Evaluate(r).([]byte)
+		return r.effect(func() interface{} {
+			n, err := stream.Read(data)
+			r.Check(err)
+			return big.NewInt(int64(n))
+		})
+	})
+}
+
+//Send writes the string data into the stream, returns the number of bytes written.
+//If the stream is nil, then the runtime's Stdout is written to.
+//This may throw an error.
+func (t *Target) Send(stream usm.Stream, data usm.String) usm.Value {
+	var d = data.(Value)
+	if stream == nil {
+		return NewValue(func(r *Runtime) interface{} {
+			var data = d.Evaluate(r).([]byte)
+			return r.effect(func() interface{} {
+				n, err := r.Host.stdout().Write(data)
+				r.Check(err)
+				return big.NewInt(int64(n))
+			})
+		})
+	}
+	var s = stream.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var stream, data = s.Evaluate(r).(*Stream), d.Evaluate(r).([]byte)
+		return r.effect(func() interface{} {
+			n, err := stream.Write(data)
+			r.Check(err)
+			return big.NewInt(int64(n))
+		})
+	})
+}
+
+//Fork starts a new runtime on a goroutine that runs the given label with the arguments.
+//The returned stream reads from the Stdout of the new runtime and sends to its Stdin.
+//The arguments are copied with Clone, so the runtimes never share a mutable value.
+//The new runtime is cancelled when the stream is closed, which happens when this runtime finishes.
+//If it halts or leaves errors on its error stack, then reading from or sending to the stream throws the error.
+//It shares the Limits of this runtime, which counts ForkSize bytes for it.
+func (r *Runtime) Fork(label usm.Label, args ...interface{}) *Stream {
+	r.Allocate(ForkSize)
+
+	var stdin, input = io.Pipe()
+	var output, stdout = io.Pipe()
+
+	var parent = r.context
+	if parent == nil {
+		parent = context.Background()
+	}
+	var ctx, cancel = context.WithCancel(parent)
+
+	var copies = make(map[interface{}]interface{})
+	var cloned = make([]interface{}, len(args))
+	for i, arg := range args {
+		cloned[i] = clone(arg, copies)
+	}
+
+	var child = &Runtime{
+		Entrypoint: r.Entrypoint,
+		Blocks:     r.Blocks,
+
+		Host: Host{
+			Stdin:  stdin,
+			Stdout: stdout,
+			Stderr: r.Host.Stderr,
+			Open:   r.Host.Open,
+		},
+
+		Limits:  r.Limits,
+		shared:  r.budget(),
+		context: ctx,
+	}
+
+	go func() {
+		var err error
+		defer cancel()
+		defer func() {
+			stdin.CloseWithError(err)
+			stdout.CloseWithError(err)
+		}()
+		defer child.Close()
+		defer child.rescue(&err)
+
+		child.Jump(label, cloned...)
+		if len(child.Thrown) > 0 {
+			err = &ThrownError{Values: child.Thrown}
+		}
+	}()
+
+	var stream = &Stream{
+		Reader:  output,
+		Writer:  input,
+		Closers: []io.Closer{input, output, canceller(cancel)},
+	}
+	r.Streams = append(r.Streams, stream)
+	return stream
+}
+
+//canceller is an io.Closer that cancels a context.
+type canceller context.CancelFunc
+
+func (c canceller) Close() error {
+	c()
+	return nil
+}
+
+//Clone returns a deep copy of the value, strings, arrays, tables, pointers and numbers are copied.
+//Values that are shared within the value, including cycles, remain shared within the copy.
+//Streams and functions are not copied.
+func Clone(value interface{}) interface{} {
+	return clone(value, make(map[interface{}]interface{}))
+}
+
+//clone copies the value, copies holds the values that have already been copied.
+func clone(value interface{}, copies map[interface{}]interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return new(big.Int).Set(v)
+	case []byte:
+		if len(v) == 0 {
+			return []byte{}
+		}
+		var key = slice{&v[0], len(v)}
+		if c, ok := copies[key]; ok {
+			return c
+		}
+		var c = append([]byte{}, v...)
+		copies[key] = c
+		return c
+	case *Array:
+		if c, ok := copies[v]; ok {
+			return c
+		}
+		var c = &Array{Values: make([]interface{}, len(v.Values))}
+		copies[v] = c
+		for i, element := range v.Values {
+			c.Values[i] = clone(element, copies)
+		}
+		return c
+	case *Table:
+		if c, ok := copies[v]; ok {
+			return c
+		}
+		var c = &Table{Values: make(map[string]interface{}, len(v.Values))}
+		copies[v] = c
+		for key, element := range v.Values {
+			c.Values[key] = clone(element, copies)
+		}
+		return c
+	case *Pointer:
+		if c, ok := copies[v]; ok {
+			return c
+		}
+		var c = new(Pointer)
+		copies[v] = c
+		c.Value = clone(v.Value, copies)
+		return c
+	default:
+		return value
+	}
+}
+
+//Fork jumps to the label in an independant parallel runtime, the arguments are passed.
+//A connected stream is returned, this connects to the Stdin and Stdout of the new runtime.
+func (t *Target) Fork(label usm.Label, args ...usm.Value) usm.Stream {
+	var converted = values(args)
+	return NewValue(func(r *Runtime) interface{} {
+		var args = evaluate(r, converted)
+		return r.effect(func() interface{} {
+			return r.Fork(label, args...)
+		})
+	})
+}
+
+//Open returns a stream from the given platform-dependent URI.
+//This may throw an error.
+func (t *Target) Open(uri usm.String) usm.Stream {
+	var u = uri.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var uri = string(u.Evaluate(r).([]byte))
+		return r.effect(func() interface{} {
+			var stream, err = r.Host.open(uri)
+			if err != nil {
+				r.Check(err)
+				return new(Stream)
+			}
+			r.Streams = append(r.Streams, stream)
+			return stream
+		})
+	})
+}
+
+//Stat returns the name, size in bytes and mode of the file behind the stream, separated by spaces.
+//If the stream is nil, then the runtime's Stdin is stat'ed.
+//An error is thrown if the stream is not a file.
+func (t *Target) Stat(stream usm.Stream) usm.String {
+	var s Value
+	if stream != nil {
+		s = stream.(Value)
+	}
+	return NewValue(func(r *Runtime) interface{} {
+		var candidates []interface{}
+		if s == nil {
+			candidates = append(candidates, r.Host.stdin())
+		} else {
+			var stream = s.Evaluate(r).(*Stream)
+			candidates = append(candidates, stream.Reader, stream.Writer)
+		}
+		return r.effect(func() interface{} {
+			for _, candidate := range candidates {
+				if file, ok := candidate.(interface{ Stat() (os.FileInfo, error) }); ok {
+					info, err := file.Stat()
+					if err != nil {
+						r.Check(err)
+						return []byte{}
+					}
+					return []byte(fmt.Sprintf("%v %v %v", info.Name(), info.Size(), info.Mode()))
+				}
+			}
+			r.Raise([]byte("stream is not a file"))
+			return []byte{}
+		})
+	})
+}
+
+//Seek attempts to advance the stream by discarding a specified number of bytes from the stream.
+func (t *Target) Seek(stream usm.Stream, amount usm.Number) {
+	var n = amount.(Value)
+	var s Value
+	if stream != nil {
+		s = stream.(Value)
+	}
+	t.Write(func(r *Runtime) {
+		var reader io.Reader
+		if s == nil {
+			reader = r.Host.stdin()
+		} else {
+			reader = s.Evaluate(r).(*Stream)
+		}
+		_, err := io.CopyN(ioutil.Discard, reader, n.Evaluate(r).(*big.Int).Int64())
+		r.Check(err)
+	})
+}
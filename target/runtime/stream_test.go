@@ -0,0 +1,101 @@
+package runtime_test
+
+import (
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestFork(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target)
+		output  string
+	}{
+		{"pipes", func(c *runtime.Target) {
+			c.Main(func() {
+				var echo = c.Define(0, func() {
+					var data = c.Var(c.Create(number(c, 3)))
+					c.Discard(c.Read(nil, c.Get(data)))
+					c.Discard(c.Send(nil, c.Get(data)))
+				})
+				var stream = c.Var(c.Fork(echo))
+				c.Discard(c.Send(c.Get(stream), c.String("abc")))
+				var data = c.Var(c.Create(number(c, 3)))
+				c.Discard(c.Read(c.Get(stream), c.Get(data)))
+				c.Discard(c.Send(nil, c.Get(data)))
+			})
+		}, "abc"},
+		{"arguments are copies", func(c *runtime.Target) {
+			var show = show(c)
+			c.Main(func() {
+				var change = c.Define(1, func() {
+					c.Mutate(c.Get(usm.Arg(0)), number(c, 0), number(c, 2))
+					c.Discard(c.Send(nil, c.String("x")))
+				})
+				var array = c.Var(c.Array(number(c, 1)))
+				var stream = c.Var(c.Fork(change, c.Get(array)))
+				c.Discard(c.Read(c.Get(stream), c.Create(number(c, 1))))
+				c.JumpTo(show, c.Index(c.Get(array), number(c, 0)))
+			})
+		}, "1"},
+		{"thrown errors", func(c *runtime.Target) {
+			c.Main(func() {
+				var undefined = c.Define(0, func() {
+					c.JumpTo(usm.Label(100))
+				})
+				var stream = c.Var(c.Fork(undefined))
+				c.Discard(c.Read(c.Get(stream), c.Create(number(c, 1))))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "runtime: thrown: undefined function"},
+		{"halts", func(c *runtime.Target) {
+			c.Main(func() {
+				var forever = c.Define(0, func() {
+					c.JumpTo(1)
+				})
+				var stream = c.Var(c.Fork(forever))
+				c.Discard(c.Send(c.Get(stream), c.String("x")))
+				c.Discard(c.Send(nil, c.Catch()))
+			})
+		}, "runtime: depth limit of 10000 exceeded"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, test.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
+
+func TestForkCancelled(t *testing.T) {
+	var goroutines = goruntime.NumGoroutine()
+
+	var _, err = run(t, runtime.Limits{}, func(c *runtime.Target) {
+		c.Main(func() {
+			var forever = c.Define(0, func() {
+				c.Loop(nil, func() {})
+			})
+			c.Discard(c.Fork(forever))
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the forked runtime only stops once it notices the cancellation.
+	for deadline := time.Now().Add(5 * time.Second); goruntime.NumGoroutine() > goroutines; {
+		if time.Now().After(deadline) {
+			t.Fatal("the forked runtime was not cancelled when its parent finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
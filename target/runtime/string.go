@@ -0,0 +1,98 @@
+package runtime
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//Strings are represented as []byte.
+//Modify changes the bytes in place, so every copy of a String sees the change.
+
+//String returns the String given by the go.string
+func (t *Target) String(s string) usm.Value {
+	return NewValue(func(r *Runtime) interface{} {
+		return []byte(s)
+	})
+}
+
+//Create creates a new String of the given size.
+func (t *Target) Create(n usm.Number) usm.String {
+	var size = n.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var n = size.Evaluate(r).(*big.Int)
+		return r.effect(func() interface{} {
+			if n.Sign() < 0 || !n.IsInt64() {
+				r.Raise([]byte("invalid string size"))
+				return []byte{}
+			}
+			r.Allocate(n.Int64())
+			return make([]byte, n.Int64())
+		})
+	})
+}
+
+//Length returns the length of the String in bytes.
+func (t *Target) Length(s usm.String) usm.Number {
+	var S = s.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return big.NewInt(int64(len(S.Evaluate(r).([]byte))))
+	})
+}
+
+//Equals returns 1 is the two Strings are equal. Returns 0 otherwise.
+func (t *Target) Equals(a usm.String, b usm.String) usm.Bit {
+	var A = a.(Value)
+	var B = b.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return bytes.Equal(A.Evaluate(r).([]byte), B.Evaluate(r).([]byte))
+	})
+}
+
+//Symbol returns the byte at the given index in the String.
+func (t *Target) Symbol(data usm.String, index usm.Number) usm.Number {
+	var d = data.(Value)
+	var i = index.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var data, index = d.Evaluate(r).([]byte), i.Evaluate(r).(*big.Int)
+		if !r.Bounds(index, len(data)) {
+			return new(big.Int)
+		}
+		return big.NewInt(int64(data[index.Int64()]))
+	})
+}
+
+//Concat creates a new String that is the concatenation of the given strings.
+func (t *Target) Concat(a usm.String, b usm.String) usm.String {
+	var A = a.(Value)
+	var B = b.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		var a, b = A.Evaluate(r).([]byte), B.Evaluate(r).([]byte)
+		return r.effect(func() interface{} {
+			r.Allocate(int64(len(a) + len(b)))
+
+			var concat = make([]byte, 0, len(a)+len(b))
+			concat = append(concat, a...)
+			return append(concat, b...)
+		})
+	})
+}
+
+//Modify mutates a string and sets the index to be set to the given number.
+//Throws an error if the number does not fit in a byte.
+func (t *Target) Modify(s usm.String, index usm.Number, number usm.Number) {
+	var S = s.(Value)
+	var i = index.(Value)
+	var n = number.(Value)
+	t.Write(func(r *Runtime) {
+		var data, index, number = S.Evaluate(r).([]byte), i.Evaluate(r).(*big.Int), n.Evaluate(r).(*big.Int)
+		if number.Sign() < 0 || number.BitLen() > 8 {
+			r.Raise([]byte("invalid byte"))
+			return
+		}
+		if r.Bounds(index, len(data)) {
+			data[index.Int64()] = byte(number.Int64())
+		}
+	})
+}
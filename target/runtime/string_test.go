@@ -0,0 +1,80 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm"
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestStrings(t *testing.T) {
+	var tests = []struct {
+		name    string
+		program func(c *runtime.Target, show usm.Label)
+		output  string
+	}{
+		{"create", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(show, c.Length(c.Create(number(c, 3))))
+		}, "3"},
+		{"invalid create", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Create(number(c, -1)))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "invalid string size"},
+		{"length", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(show, c.Length(c.String("hello")))
+		}, "5"},
+		{"symbol", func(c *runtime.Target, show usm.Label) {
+			c.JumpTo(show, c.Symbol(c.String("ab"), number(c, 1)))
+		}, "98"},
+		{"equals", func(c *runtime.Target, show usm.Label) {
+			c.If(c.Equals(c.String("ab"), c.String("ab")), func() {
+				c.Discard(c.Send(nil, c.String("same;")))
+			}, nil, nil)
+			c.If(c.Equals(c.String("ab"), c.String("ac")), func() {}, nil, func() {
+				c.Discard(c.Send(nil, c.String("different;")))
+			})
+			c.If(c.Equals(c.String("ab"), c.String("abc")), func() {}, nil, func() {
+				c.Discard(c.Send(nil, c.String("longer")))
+			})
+		}, "same;different;longer"},
+		{"concat", func(c *runtime.Target, show usm.Label) {
+			c.Discard(c.Send(nil, c.Concat(c.String("ab"), c.String("cd"))))
+		}, "abcd"},
+		{"concat copies", func(c *runtime.Target, show usm.Label) {
+			var a = c.Var(c.String("ab"))
+			var concat = c.Var(c.Concat(c.Get(a), c.String("c")))
+			c.Modify(c.Get(a), number(c, 0), number(c, 'x'))
+			c.Discard(c.Send(nil, c.Get(concat)))
+		}, "abc"},
+		{"modify in place", func(c *runtime.Target, show usm.Label) {
+			var s = c.Var(c.String("abc"))
+			var copy = c.Var(c.Get(s))
+			c.Modify(c.Get(copy), number(c, 0), number(c, 'x'))
+			c.Discard(c.Send(nil, c.Get(s)))
+		}, "xbc"},
+		{"modify invalid byte", func(c *runtime.Target, show usm.Label) {
+			c.Modify(c.String("abc"), number(c, 0), number(c, 256))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "invalid byte"},
+		{"modify out of range", func(c *runtime.Target, show usm.Label) {
+			c.Modify(c.String("abc"), number(c, 3), number(c, 'x'))
+			c.Discard(c.Send(nil, c.Catch()))
+		}, "index out of range"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output, err = run(t, runtime.Limits{}, func(c *runtime.Target) {
+				var show = show(c)
+				c.Main(func() {
+					test.program(c, show)
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != test.output {
+				t.Fatalf("expected %q, got %q", test.output, output)
+			}
+		})
+	}
+}
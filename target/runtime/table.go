@@ -0,0 +1,79 @@
+package runtime
+
+import (
+	"math/big"
+
+	"github.com/qlova/usm"
+)
+
+//Table is a runtime usm.Table, tables are shared by reference and keyed by String.
+type Table struct {
+	Values map[string]interface{}
+}
+
+//Table creates a new table with the given elements.
+func (t *Target) Table(elements map[usm.Value]usm.Value) usm.Table {
+	type Element struct {
+		Key, Value Value
+	}
+
+	var converted = make([]Element, 0, len(elements))
+	for key, value := range elements {
+		converted = append(converted, Element{key.(Value), value.(Value)})
+	}
+
+	return NewValue(func(r *Runtime) interface{} {
+		var values = make(map[string]interface{}, len(converted))
+		var size int64
+		for _, element := range converted {
+			var key = string(element.Key.Evaluate(r).([]byte))
+			values[key] = element.Value.Evaluate(r)
+			size += ElementSize + int64(len(key))
+		}
+		return r.effect(func() interface{} {
+			r.Allocate(size)
+			return &Table{Values: values}
+		})
+	})
+}
+
+//Amount returns the number of items in the Table.
+func (t *Target) Amount(table usm.Table) usm.Value {
+	var T = table.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return big.NewInt(int64(len(T.Evaluate(r).(*Table).Values)))
+	})
+}
+
+//Lookup returns the value at the given key in the Table.
+func (t *Target) Lookup(table usm.Table, key usm.String) usm.Value {
+	var T = table.(Value)
+	var k = key.(Value)
+	return NewValue(func(r *Runtime) interface{} {
+		return T.Evaluate(r).(*Table).Values[string(k.Evaluate(r).([]byte))]
+	})
+}
+
+//Insert sets the table value at the given string key to be set to the given value.
+func (t *Target) Insert(table usm.Table, key usm.String, value usm.Value) {
+	var T = table.(Value)
+	var k = key.(Value)
+	var v = value.(Value)
+	t.Write(func(r *Runtime) {
+		var table, key = T.Evaluate(r).(*Table), string(k.Evaluate(r).([]byte))
+		var value = v.Evaluate(r)
+		if _, ok := table.Values[key]; !ok {
+			r.Allocate(ElementSize + int64(len(key)))
+		}
+		table.Values[key] = value
+	})
+}
+
+//Remove removes the given key from the table.
+func (t *Target) Remove(table usm.Value, key usm.Value) {
+	var T = table.(Value)
+	var k = key.(Value)
+	t.Write(func(r *Runtime) {
+		delete(T.Evaluate(r).(*Table).Values, string(k.Evaluate(r).([]byte)))
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"io"
 	"math/big"
-	"os"
 
 	"github.com/qlova/usm"
 	"github.com/qlova/usm/template"
@@ -15,20 +14,62 @@ type Target struct {
 	template.Target
 
 	Runtime
+
+	//Natives are the labels of the registered native functions.
+	Natives map[string]usm.Label
+
+	//blocks is the number of blocks built, it numbers each Block.
+	blocks int
+
+	//statements is the number of statements written to the current function, it numbers each statement.
+	statements int
 }
 
 //Block returns a Block from a usm.Block
 func (t *Target) Block(body usm.Block) Block {
 	var old = t.Current
-	t.Current = new(Block)
+	t.blocks++
+	t.number()
+	t.Current = &Block{ID: t.blocks}
 	body()
 	var block = *t.Current
 	t.Current = old
 	return block
 }
 
-func (t *Target) Write(f func()) {
+//Function returns a function Block from a usm.Block, with its own registers, see usm.Register.
+func (t *Target) Function(body usm.Block) Block {
+	var old, registers, statements = t.Current, t.Registers, t.statements
+	t.blocks++
+	t.Current = &Block{ID: t.blocks, Function: true}
+	t.Registers, t.statements = 0, 0
+	body()
+	var block = *t.Current
+	block.Registers = int(t.Registers)
+	t.Current, t.Registers, t.statements = old, registers, statements
+	return block
+}
+
+//number numbers the statement that is being written to the current block, if it has not been numbered yet.
+//Statements are numbered before the blocks inside of them are built, so that they are numbered in the order they are written.
+func (t *Target) number() {
+	if t.Current != nil && t.Current.next == 0 {
+		t.statements++
+		t.Current.next = t.statements
+	}
+}
+
+//register reserves a new register in the current function.
+func (t *Target) register() usm.Register {
+	t.Registers++
+	return t.Registers
+}
+
+func (t *Target) Write(f func(*Runtime)) {
+	t.number()
 	t.Current.Statements = append(t.Current.Statements, f)
+	t.Current.Lines = append(t.Current.Lines, t.Current.next-1)
+	t.Current.next = 0
 }
 
 //WriteTo writes the target.
@@ -38,106 +79,131 @@ func (t *Target) WriteTo(writer io.Writer) (int64, error) {
 
 //Main is the entrypoint of the program.
 func (t *Target) Main(body usm.Block) {
-	t.Entrypoint = new(Block)
-	t.Current = t.Entrypoint
-	body()
-	t.Current = nil
+	var main = t.Function(body)
+	t.Entrypoint = &main
 }
 
-//String returns the String given by the go.string
-func (t *Target) String(s string) usm.Value {
-	return Value(func() interface{} {
-		return []byte(s)
-	})
-}
-
-//Create creates a new String of the given size.
-func (t *Target) Create(n usm.Number) usm.String {
-	var size = n.(Value)
-	return Value(func() interface{} {
-		return make([]byte, size().(*big.Int).Int64())
-	})
+//NewRuntime returns a new Runtime for the program that the target built.
+//Runtimes share the program but none of its state, so a program that is built once,
+//for example from bytecode, can be run many times concurrently.
+//The Host and Limits of the target are copied to the new Runtime.
+func (t *Target) NewRuntime() *Runtime {
+	return &Runtime{
+		Entrypoint: t.Entrypoint,
+		Blocks:     t.Blocks,
+		Host:       t.Host,
+		Limits:     t.Limits,
+	}
 }
 
 //Bit returns the Bit given by the go.bool
 func (t *Target) Bit(b bool) usm.Value {
-	return Value(func() interface{} {
+	return NewValue(func(r *Runtime) interface{} {
 		return b
 	})
 }
 
-//Read reads stream data into the given string, returns the number of bytes read.
-//This may throw an error.
-func (t *Target) Read(stream usm.Stream, data usm.String) usm.Value {
-	if stream == nil {
-		var data = data.(Value)
-		return Value(func() interface{} {
-			n, _ := os.Stdin.Read(data().([]byte))
-			return n
-		})
-	}
-	panic("not implemented")
-}
-
-//Send writes the string data into the stream, returns the number of bytes written.
-//This may throw an error.
-func (t *Target) Send(stream usm.Stream, s usm.String) usm.Value {
-	if stream == nil {
-		var s = s.(Value)
-		return Value(func() interface{} {
-			n, _ := os.Stdout.Write(s().([]byte))
-			return n
-		})
-	}
-	panic("not implemented")
-}
-
 //Discard allows a value to be used as a statement.
 func (t *Target) Discard(value usm.Value) {
 	var f = value.(Value)
-	t.Write(func() {
-		_ = f()
+	t.Write(func(r *Runtime) {
+		_ = f.Evaluate(r)
 	})
 }
 
 //Define defines a function, returning the label to the function.
 //arguments is the number of the arguments the function expects.
+//The label is reserved before the body is built, so that the body is able to call itself.
 func (t *Target) Define(arguments int, body usm.Block) usm.Label {
 	t.Labels++
-	var old = t.Current
-	t.Current = new(Block)
-	body()
-	t.Blocks = append(t.Blocks, *t.Current)
-	t.Current = old
-	return usm.Label(len(t.Blocks))
+	var label = usm.Label(len(t.Blocks) + 1)
+	t.Blocks = append(t.Blocks, Block{Label: label})
+
+	var function = t.Function(body)
+	function.Arguments = arguments
+	function.Label = label
+	t.Blocks[label-1] = function
+	return label
+}
+
+//Delete frees the memory of the given Value.
+//The runtime is garbage collected, so the value is only evaluated.
+func (t *Target) Delete(_ usm.Type, value usm.Value) {
+	var f = value.(Value)
+	t.Write(func(r *Runtime) {
+		_ = f.Evaluate(r)
+	})
 }
 
 //Var creates a new variable set to the provided value.
 //Returns the register for future reference to the variable.
 func (t *Target) Var(value usm.Value) usm.Register {
-	t.Registers++
+	var register = t.register()
+	var val = value.(Value)
 
+	t.Write(func(r *Runtime) {
+		r.Variables[register-1] = val.Evaluate(r)
+	})
+
+	return register
+}
+
+//Set sets the variable in the given register to be the given value.
+func (t *Target) Set(register usm.Register, value usm.Value) {
 	var val = value.(Value)
 
-	t.Write(func() {
-		t.Variables[t.Registers] = val()
+	if register < 0 {
+		t.Write(func(r *Runtime) {
+			r.Args[-register-1] = val.Evaluate(r)
+		})
+		return
+	}
+
+	t.Write(func(r *Runtime) {
+		r.Variables[register-1] = val.Evaluate(r)
 	})
+}
 
-	return t.Registers
+//values converts the usm.Values into runtime Values.
+func values(args []usm.Value) []Value {
+	var converted = make([]Value, len(args))
+	for i := range args {
+		converted[i] = args[i].(Value)
+	}
+	return converted
+}
+
+//evaluate evaluates each of the values.
+func evaluate(r *Runtime, args []Value) []interface{} {
+	var evaluated = make([]interface{}, len(args))
+	for i := range args {
+		evaluated[i] = args[i].Evaluate(r)
+	}
+	return evaluated
 }
 
 //JumpTo jumps to the label passing the provided arguments.
 //JumpTo ignores any return values.
 //If the label is 0, then the first argument is treated as a label bind and subsequent arguments are passed.
 func (t *Target) JumpTo(label usm.Label, arguments ...usm.Value) {
-	t.Write(func() {
-		t.Blocks[label-1].RunWith(&t.Runtime)
+	var args = values(arguments)
+	t.Write(func(r *Runtime) {
+		r.Jump(label, evaluate(r, args)...)
+		r.ReturnValue = nil
+	})
+}
+
+//Bind returns the label as a value that can be passed to a Call, JumpTo or Fork by passing an empty function argument
+func (t *Target) Bind(label usm.Label) usm.Value {
+	var function = Function{Label: label}
+	return NewValue(func(r *Runtime) interface{} {
+		return function
 	})
 }
 
 //Number returns the Number given by the go.big.Int
 func (t *Target) Number(b *big.Int) usm.Number {
-	return Value(func() interface{} {
+	return NewValue(func(r *Runtime) interface{} {
 		return b
 	})
 }
@@ -145,27 +211,28 @@ func (t *Target) Number(b *big.Int) usm.Number {
 //Call calls the provided label, passing the provided argument values and returns the result.
 //If the label is 0, then the first argument is treated as a label bind and subsequent arguments are passed.
 func (t *Target) Call(label usm.Label, args ...usm.Value) usm.Value {
-	return Value(func() interface{} {
-
-		var converted []interface{}
-		for i := range args {
-			converted[i] = args[i].(Value)()
-		}
-
-		t.Blocks[label-1].RunWith(&t.Runtime, converted...)
-		return t.ReturnValue
+	var converted = values(args)
+	return NewValue(func(r *Runtime) interface{} {
+		var args = evaluate(r, converted)
+		return r.effect(func() interface{} {
+			r.Jump(label, args...)
+
+			var result = r.ReturnValue
+			r.ReturnValue = nil
+			return result
+		})
 	})
 }
 
 //Get returns the value inside of the given register.
-func (t *Target) Get(r usm.Register) usm.Value {
-	if r < 0 {
-		return Value(func() interface{} {
-			return t.Args[-r]
+func (t *Target) Get(register usm.Register) usm.Value {
+	if register < 0 {
+		return NewValue(func(r *Runtime) interface{} {
+			return r.Args[-register-1]
 		})
 	}
-	return Value(func() interface{} {
-		return t.Variables[r]
+	return NewValue(func(r *Runtime) interface{} {
+		return r.Variables[register-1]
 	})
 }
 
@@ -173,16 +240,16 @@ func (t *Target) Get(r usm.Register) usm.Value {
 //Pass nil to return without passing a value.
 func (t *Target) Return(result usm.Value) {
 	if result == nil {
-		t.Write(func() {
-			t.ReturnValue = nil
-			t.Returning = true
+		t.Write(func(r *Runtime) {
+			r.ReturnValue = nil
+			r.Returning = true
 		})
 		return
 	}
-	var r = result.(Value)
-	t.Write(func() {
-		t.ReturnValue = r()
-		t.Returning = true
+	var v = result.(Value)
+	t.Write(func(r *Runtime) {
+		r.ReturnValue = v.Evaluate(r)
+		r.Returning = true
 	})
 
 }
@@ -191,87 +258,47 @@ func (t *Target) Return(result usm.Value) {
 //If the condition is zero, this process follows the chain, treating them as elseif's.
 //The last block is branched to if none of the previous branches were followed.
 func (t *Target) If(condition usm.Bit, body usm.Block, chain []usm.ElseIf, last usm.Block) {
-
-	var c = condition.(Value)
-	var first = t.Block(body)
-
-	type ElseIf struct {
+	type Branch struct {
 		Value
 		Block
 	}
 
-	var converted []ElseIf
+	var branches = make([]Branch, 0, len(chain)+1)
+	branches = append(branches, Branch{
+		Value: condition.(Value),
+		Block: t.Block(body),
+	})
 	for i := range chain {
-		converted[i] = ElseIf{
+		branches = append(branches, Branch{
 			Value: chain[i].Bit.(Value),
 			Block: t.Block(chain[i].Block),
-		}
+		})
 	}
 
-	if last == nil {
-		t.Write(func() {
-			if c().(bool) {
-				first.RunWith(&t.Runtime)
-			}
+	var otherwise Block
+	if last != nil {
+		otherwise = t.Block(last)
+	}
 
-			for i := range converted {
-				if (converted[i].Value()).(bool) {
-					converted[i].Block.RunWith(&t.Runtime)
+	t.Write(func(r *Runtime) {
+		if len(r.resume) > 0 {
+			for i := range branches {
+				if r.resuming(branches[i].Block) {
+					branches[i].Block.RunWith(r)
 					return
 				}
 			}
-		})
-		return
-	}
-
-	var l = t.Block(last)
-
-	t.Write(func() {
-		if c().(bool) {
-			first.RunWith(&t.Runtime)
-		}
-
-		for i := range converted {
-			if (converted[i].Value()).(bool) {
-				converted[i].Block.RunWith(&t.Runtime)
+			if r.resuming(otherwise) {
+				otherwise.RunWith(r)
 				return
 			}
 		}
-
-		l.RunWith(&t.Runtime)
-	})
-}
-
-//Loop loops the body while an optional condition is true.
-//If condition is nil, then the loop is infinite.
-func (t *Target) Loop(condition usm.Number, body usm.Block) {
-	var block = t.Block(body)
-
-	if condition == nil {
-		t.Write(func() {
-			for {
-				block.RunWith(&t.Runtime)
+		for i := range branches {
+			if Truth(branches[i].Value.Evaluate(r)) {
+				branches[i].Block.RunWith(r)
+				return
 			}
-		})
-	} else {
-		panic("not implimented")
-	}
-}
-
-//Symbol returns the byte at the given index in the String.
-func (t *Target) Symbol(data usm.String, index usm.Number) usm.Number {
-	var d = data.(Value)
-	var i = index.(Value)
-	return Value(func() interface{} {
-		return (d().([]byte))[i().(*big.Int).Int64()]
-	})
-}
-
-//Same returns 1 if a is equal to b, otherwise 0.
-func (t *Target) Same(a usm.Number, b usm.Number) usm.Bit {
-	var A = a.(Value)
-	var B = b.(Value)
-	return Value(func() interface{} {
-		return (A().(*big.Int)).Cmp(B().(*big.Int)) == 0
+		}
+		otherwise.RunWith(r)
 	})
 }
@@ -0,0 +1,182 @@
+package runtime
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qlova/usm"
+)
+
+//Trace is the time spent in the function at a label.
+type Trace struct {
+	//Calls is the number of times the function was called.
+	Calls int64
+
+	//Cumulative is the time spent in the function, including the functions that it called.
+	//Recursive calls are only counted once.
+	Cumulative time.Duration
+
+	//Self is the time spent in the function, excluding the functions that it called.
+	Self time.Duration
+
+	//Statements is the number of statements executed by the function.
+	Statements int64
+}
+
+//Tracer records where a Runtime spends its time.
+//The results are reset each time the Runtime runs and must not be read while it is running.
+type Tracer struct {
+	//Labels holds the trace of each function that was called, Main is label 0.
+	Labels map[usm.Label]*Trace
+
+	//Statements is the number of times each statement was executed.
+	Statements map[Location]int64
+
+	//Duration is the total time that the runtime ran for.
+	Duration time.Duration
+
+	started, last time.Time
+
+	//stack is the location of each function call, the current location last.
+	stack  []Location
+	starts []time.Time
+	active map[usm.Label]int
+
+	samples map[string]*sample
+}
+
+//sample is the cost of a call stack.
+type sample struct {
+	stack      []Location
+	statements int64
+	time       time.Duration
+}
+
+//NewTracer returns a new Tracer attached to the runtime.
+func NewTracer(r *Runtime) *Tracer {
+	var t = new(Tracer)
+	t.reset(time.Now())
+	r.Tracer = t
+	return t
+}
+
+func (t *Tracer) reset(now time.Time) {
+	t.Labels = make(map[usm.Label]*Trace)
+	t.Statements = make(map[Location]int64)
+	t.Duration = 0
+	t.started, t.last = now, now
+	t.stack, t.starts = nil, nil
+	t.active = make(map[usm.Label]int)
+	t.samples = make(map[string]*sample)
+}
+
+//Functions returns the labels of the traced functions, in order of their self time, the most expensive first.
+func (t *Tracer) Functions() []usm.Label {
+	var labels = make([]usm.Label, 0, len(t.Labels))
+	for label := range t.Labels {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		var a, b = t.Labels[labels[i]], t.Labels[labels[j]]
+		if a.Self != b.Self {
+			return a.Self > b.Self
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
+
+func (t *Tracer) trace(label usm.Label) *Trace {
+	var trace, ok = t.Labels[label]
+	if !ok {
+		trace = new(Trace)
+		t.Labels[label] = trace
+	}
+	return trace
+}
+
+//sample returns the sample for the current stack.
+func (t *Tracer) sample() *sample {
+	var key strings.Builder
+	for _, location := range t.stack {
+		key.WriteString(strconv.FormatUint(uint64(location.Label), 10))
+		key.WriteByte(':')
+		key.WriteString(strconv.Itoa(location.Statement))
+		key.WriteByte(' ')
+	}
+	var s, ok = t.samples[key.String()]
+	if !ok {
+		s = &sample{stack: append([]Location{}, t.stack...)}
+		t.samples[key.String()] = s
+	}
+	return s
+}
+
+//tick attributes the time since the last event to the current location.
+func (t *Tracer) tick(now time.Time) {
+	var elapsed = now.Sub(t.last)
+	t.last = now
+	if len(t.stack) == 0 {
+		return
+	}
+	t.trace(t.stack[len(t.stack)-1].Label).Self += elapsed
+	t.sample().time += elapsed
+}
+
+//start is called by the runtime when it starts running Main.
+func (t *Tracer) start() {
+	t.reset(time.Now())
+	t.enter(0)
+}
+
+//stop is called by the runtime when it stops running.
+func (t *Tracer) stop() {
+	for len(t.stack) > 0 {
+		t.exit()
+	}
+	t.Duration = t.last.Sub(t.started)
+}
+
+//enter is called by the runtime before calling the function at the label.
+func (t *Tracer) enter(label usm.Label) {
+	var now = time.Now()
+	t.tick(now)
+	t.stack = append(t.stack, Location{Label: label})
+	t.starts = append(t.starts, now)
+	t.active[label]++
+	t.trace(label).Calls++
+}
+
+//exit is called by the runtime after the current function returns.
+func (t *Tracer) exit() {
+	if len(t.stack) == 0 {
+		return
+	}
+	var now = time.Now()
+	t.tick(now)
+
+	var last = len(t.stack) - 1
+	var label, start = t.stack[last].Label, t.starts[last]
+	t.stack, t.starts = t.stack[:last], t.starts[:last]
+
+	t.active[label]--
+	if t.active[label] == 0 {
+		t.trace(label).Cumulative += now.Sub(start)
+	}
+}
+
+//statement is called by the runtime before each statement.
+func (t *Tracer) statement(r *Runtime) {
+	t.tick(time.Now())
+	if len(t.stack) == 0 {
+		return
+	}
+
+	var location = Location{r.Label, r.Statement}
+	t.stack[len(t.stack)-1] = location
+	t.Statements[location]++
+	t.trace(location.Label).Statements++
+	t.sample().statements++
+}
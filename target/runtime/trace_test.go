@@ -0,0 +1,22 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/qlova/usm/target/runtime"
+)
+
+func TestTracerLocations(t *testing.T) {
+	var c runtime.Target
+	nested(&c)
+	var tracer = runtime.NewTracer(&c.Runtime)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for statement, expected := range []int64{1, 3, 3, 1} {
+		if got := tracer.Statements[runtime.Location{Label: 0, Statement: statement}]; got != expected {
+			t.Errorf("statement %v was executed %v times, expected %v", statement, got, expected)
+		}
+	}
+}
@@ -20,6 +20,12 @@ type Target struct {
 	Registers usm.Register
 }
 
+//NextLabel returns the label that the next call to Define returns.
+//Targets number their functions from 1, in the order that Define is called.
+func (t *Target) NextLabel() usm.Label {
+	return t.Labels + 1
+}
+
 //Indent processes and indents the given block.
 func (t *Target) Indent(block usm.Block) {
 	t.Tabs++